@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladimir-rom/logex/steps"
+)
+
+// parseAround parses a time window specification: 'REF±WINDOW' or 'REF WINDOW',
+// where REF is a timestamp or a record reference in the format file:rnum.
+func parseAround(spec string, fileNames []string, timeProps []string) (from, to time.Time, err error) {
+	if len(spec) == 0 {
+		return time.Time{}, time.Time{}, nil
+	}
+
+	ref, window, found := strings.Cut(spec, "±")
+	if !found {
+		ref, window, found = strings.Cut(spec, "+-")
+	}
+	if !found {
+		idx := strings.LastIndexAny(spec, " \t")
+		if idx < 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --around value %q, expected 'TIME±WINDOW'", spec)
+		}
+		ref, window = spec[:idx], spec[idx+1:]
+	}
+
+	w, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --around window: %w", err)
+	}
+
+	t, err := resolveTimeRef(strings.TrimSpace(ref), fileNames, timeProps)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return t.Add(-w), t.Add(w), nil
+}
+
+func resolveTimeRef(ref string, fileNames []string, timeProps []string) (time.Time, error) {
+	if idx := strings.LastIndex(ref, ":"); idx > 0 {
+		fileName := ref[:idx]
+		if recNum, err := strconv.Atoi(ref[idx+1:]); err == nil && slices.Contains(fileNames, fileName) {
+			if fileName == "-" {
+				return time.Time{}, fmt.Errorf("records of stdin can not be referenced in --around")
+			}
+			return steps.FindRecordTime(fileName, recNum, timeProps)
+		}
+	}
+
+	t, ok := steps.ParseTime(ref)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid --around time: %s", ref)
+	}
+	return t, nil
+}
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/patterns"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+const (
+	// frequencyChangeFactor is the minimal change of the relative frequency of a template to report it
+	frequencyChangeFactor = 2
+	// minChangedCount is the minimal number of records of a template to report its frequency change
+	minChangedCount = 5
+)
+
+type templateSide struct {
+	count   int
+	levels  map[string]int
+	example steps.JSON
+}
+
+type templateComparison struct {
+	cluster  *patterns.Cluster
+	baseline templateSide
+	target   templateSide
+}
+
+type baselineComparison struct {
+	templates                  map[*patterns.Cluster]*templateComparison
+	baselineTotal, targetTotal int
+}
+
+// compareWithBaseline mines the patterns of the baseline files and then of the target files
+// with the same miner so that the templates of both sets are comparable.
+func compareWithBaseline(params *filterParams, cmd *cobra.Command) error {
+	miner := patterns.NewMiner(patterns.DefaultConfig)
+	comparison := &baselineComparison{templates: make(map[*patterns.Cluster]*templateComparison)}
+	msgPath := steps.SplitPath(params.patternField())
+	levelPath := steps.SplitPath(params.levelField())
+
+	mine := func(fileNames []string, side func(*templateComparison) *templateSide, total *int) error {
+		input, closeInput, err := openInput(fileNames, cmd)
+		if err != nil {
+			return err
+		}
+		defer closeInput()
+
+		run := *params
+		run.fileNames = fileNames
+		run.stats = func() bool { return false }
+		run.consume = func(records pipeline.Seq[steps.JSON]) error {
+			for rec, err := range records {
+				if err != nil || rec.Metadata.Removed {
+					continue
+				}
+				msg, ok := steps.MessageOf(rec.Value, msgPath)
+				if !ok {
+					continue
+				}
+
+				c := miner.Add(msg, nil)
+				tc, ok := comparison.templates[c]
+				if !ok {
+					tc = &templateComparison{cluster: c}
+					comparison.templates[c] = tc
+				}
+
+				s := side(tc)
+				s.count++
+				if s.example == nil {
+					s.example = rec.Value
+				}
+				if level, ok := steps.MessageOf(rec.Value, levelPath); ok {
+					if s.levels == nil {
+						s.levels = make(map[string]int)
+					}
+					s.levels[level]++
+				}
+				*total++
+			}
+			return nil
+		}
+		return runPipeline(&run, input, io.Discard, cmd.ErrOrStderr())
+	}
+
+	err := mine(params.baseline(), func(tc *templateComparison) *templateSide { return &tc.baseline }, &comparison.baselineTotal)
+	if err != nil {
+		return err
+	}
+	err = mine(params.fileNames, func(tc *templateComparison) *templateSide { return &tc.target }, &comparison.targetTotal)
+	if err != nil {
+		return err
+	}
+
+	return printComparison(cmd.OutOrStdout(), params.outputFormat(), comparison.changes())
+}
+
+type templateChange struct {
+	Status         string         `json:"status"`
+	ID             string         `json:"id"`
+	Template       string         `json:"template"`
+	BaselineCount  int            `json:"baseline_count"`
+	Count          int            `json:"count"`
+	BaselineLevels map[string]int `json:"baseline_levels,omitempty"`
+	Levels         map[string]int `json:"levels,omitempty"`
+	Example        steps.JSON     `json:"example"`
+}
+
+// changes returns new, disappeared and changed templates. A template is changed when its relative frequency
+// changed at least frequencyChangeFactor times or its most frequent level is different.
+func (c *baselineComparison) changes() []templateChange {
+	var res []templateChange
+	for _, tc := range c.templates {
+		change := templateChange{
+			ID:             tc.cluster.ID(),
+			Template:       tc.cluster.Template(),
+			BaselineCount:  tc.baseline.count,
+			Count:          tc.target.count,
+			BaselineLevels: tc.baseline.levels,
+			Levels:         tc.target.levels,
+			Example:        tc.target.example,
+		}
+
+		switch {
+		case tc.baseline.count == 0:
+			change.Status = "new"
+		case tc.target.count == 0:
+			change.Status = "disappeared"
+			change.Example = tc.baseline.example
+		case mainLevel(tc.baseline.levels) != mainLevel(tc.target.levels):
+			change.Status = "changed"
+		default:
+			baseFreq := float64(tc.baseline.count) / float64(c.baselineTotal)
+			targetFreq := float64(tc.target.count) / float64(c.targetTotal)
+			significant := max(tc.baseline.count, tc.target.count) >= minChangedCount &&
+				(targetFreq >= baseFreq*frequencyChangeFactor || baseFreq >= targetFreq*frequencyChangeFactor)
+			if !significant {
+				continue
+			}
+			change.Status = "changed"
+		}
+		res = append(res, change)
+	}
+
+	statusOrder := map[string]int{"new": 0, "changed": 1, "disappeared": 2}
+	slices.SortFunc(res, func(a, b templateChange) int {
+		return cmp.Or(
+			cmp.Compare(statusOrder[a.Status], statusOrder[b.Status]),
+			cmp.Compare(max(b.Count, b.BaselineCount), max(a.Count, a.BaselineCount)),
+			cmp.Compare(a.Template, b.Template))
+	})
+	return res
+}
+
+func mainLevel(levels map[string]int) string {
+	keys := lo.Keys(levels)
+	slices.Sort(keys)
+	var res string
+	for _, l := range keys {
+		if len(res) == 0 || levels[l] > levels[res] {
+			res = l
+		}
+	}
+	return res
+}
+
+func printComparison(w io.Writer, format string, changes []templateChange) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for _, c := range changes {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	countWidth := 1
+	for _, c := range changes {
+		countWidth = max(countWidth, len(strconv.Itoa(c.BaselineCount)), len(strconv.Itoa(c.Count)))
+	}
+	for _, c := range changes {
+		example, err := json.Marshal(c.Example)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%-11s  %*d -> %*d  %s  %s%s\n%*s  %s\n",
+			c.Status, countWidth, c.BaselineCount, countWidth, c.Count, c.ID, c.Template, formatLevels(c),
+			11+2+countWidth*2+4+2+8, "", example)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatLevels(c templateChange) string {
+	if len(c.BaselineLevels) == 0 && len(c.Levels) == 0 {
+		return ""
+	}
+	format := func(levels map[string]int) string {
+		if len(levels) == 0 {
+			return "-"
+		}
+		keys := lo.Keys(levels)
+		slices.Sort(keys)
+		return strings.Join(lo.Map(keys, func(l string, _ int) string {
+			return fmt.Sprintf("%s:%d", l, levels[l])
+		}), " ")
+	}
+	return fmt.Sprintf("  [%s -> %s]", format(c.BaselineLevels), format(c.Levels))
+}
@@ -1,11 +1,15 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
@@ -14,6 +18,8 @@ import (
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/colors"
+	"github.com/vladimir-rom/logex/patterns"
 	"github.com/vladimir-rom/logex/pipeline"
 	"github.com/vladimir-rom/logex/steps"
 )
@@ -34,18 +40,33 @@ type filterParams struct {
 	// filters
 	kqlFilter     func() string
 	jq            func() string
+	jqArgs        func() []string
+	jqArgsJSON    func() []string
+	jqLibPath     func() []string
+	jqSlurp       func() string
 	include       func() []string
 	exclude       func() []string
 	includeRegexp func() []string
 	excludeRegexp func() []string
+	where         func() []string
+	whereNot      func() []string
+	around        func() string
+	patternIDs    func() []string
+	recRanges     func() []string
 
 	// properties
 	selectProps func() []string
 	hideProps   func() []string
 	expandProps func() []string
 	durationMs  func() []string
+	asDuration  func() []string
+	asBytes     func() []string
+	asNumber    func() []string
 	metadata    func() string
 
+	// registered steps
+	steps func() []string
+
 	// text formatting
 	outputFormat  func() string
 	headProps     func() []string
@@ -54,18 +75,68 @@ type filterParams struct {
 	textDelim     func() string
 	textNoProp    func() bool
 	highlights    func() []string
+	outputs       func() []string
 
 	// post processing
-	distinctBy func() string
-	mergeBy    func() []string
-	first      func() int
-	last       func() int
-	context    func() int
+	distinctBy     func() []string
+	distinctKeep   func() string
+	distinctCount  func() bool
+	distinctPrefix func() string
+	distinctLimit  func() int
+	sample         func() string
+	collapse       func() bool
+	collapseBy     func() []string
+	collapseWindow func() string
+	pairBy         func() []string
+	pairStart      func() string
+	pairEnd        func() string
+	pairTimeout    func() string
+	mergeBy        func() []string
+	timeFields     func() []string
+	first          func() int
+	last           func() int
+	context        func() int
+	before         func() int
+	after          func() int
+	groupSeparator func() string
+	contextBy      func() string
+	contextByLimit func() int
+	contextByMax   func() int
 
 	// debug
-	showErrors func() bool
+	showErrors  func() bool
+	stats       func() bool
+	explainPlan func() bool
+	showRemoved func() bool
+	why         func() string
+
+	// patterns
+	patterns     func() bool
+	patternField func() string
+	levelField   func() string
+	baseline     func() []string
+
+	// histogram
+	histogram      func() string
+	histogramBy    func() string
+	histogramWidth func() int
+	histogramASCII func() bool
+
+	// top values
+	top       func() []string
+	topN      func() int
+	topApprox func() int
+
+	queries func() []string
 
 	propertiesConfig config.Properties
+	queriesConfig    config.Queries
+	macrosConfig     config.Macros
+
+	// patternTemplates are the templates resolved from patternIDs
+	patternTemplates []*patterns.Cluster
+	// consume processes the resulting records instead of writing them to the outputs
+	consume func(records pipeline.Seq[steps.JSON]) error
 }
 
 type fileDescr struct {
@@ -101,7 +172,13 @@ func createRootCmd() *cobra.Command {
 		config.NewRegistry(k, filterCmd.Flags()),
 		&params)
 
-	filterCmd.Flags().StringVar(
+	filterCmd.AddCommand(createStepsCmd())
+	filterCmd.AddCommand(createQueriesCmd())
+	filterCmd.AddCommand(createStatsCmd())
+	filterCmd.AddCommand(createFieldsCmd())
+	filterCmd.AddCommand(createTraceCmd())
+
+	filterCmd.PersistentFlags().StringVar(
 		&params.config,
 		"config",
 		"",
@@ -125,7 +202,11 @@ func loadConfiguration(params *filterParams, k *koanf.Koanf, cmd *cobra.Command)
 
 	k.Unmarshal("properties", &params.propertiesConfig)
 
-	return nil
+	if err := loadQueries(k, params); err != nil {
+		return err
+	}
+
+	return applyQueries(params)
 }
 
 func defineFlags(reg *config.Registry, params *filterParams) {
@@ -133,12 +214,35 @@ func defineFlags(reg *config.Registry, params *filterParams) {
 		"kql",
 		"f",
 		"",
-		"Filter in the Kibana Query Language format. Example: 'level:(error OR warn)'")
+		"Filter in the Kibana Query Language format. Example: 'level:(error OR warn)'.\n"+
+			"Range clauses on --time-fields compare timestamps in any format and accept ISO dates and date math. Example: 'ts >= now-15m and ts < now/d'")
 
 	params.jq = reg.String(
 		"jq",
 		"",
-		"Specify a jq expression for filtering or transformation. Example: '.level==\"info\" or .level==\"warn\"'")
+		"Specify a jq expression for filtering or transformation. Example: '.level==\"info\" or .level==\"warn\"'.\n"+
+			"Additional functions: totime converts timestamps to unix time in seconds, toduration converts durations like \"1m30s\" to seconds")
+
+	params.jqSlurp = reg.String(
+		"jq-slurp",
+		"",
+		"Run a jq expression once over the array of all filtered records, its results are output as records.\n"+
+			"Example: 'group_by(.endpoint) | map({endpoint: .[0].endpoint, avg: (map(.duration) | add / length)}) | .[]'")
+
+	params.jqArgs = reg.StringArray(
+		"jq-arg",
+		nil,
+		"Pass a string value to the jq expression as a variable. Format: name=value. Example: --jq-arg user=john with '.user==$user'")
+
+	params.jqArgsJSON = reg.StringArray(
+		"jq-argjson",
+		nil,
+		"Pass a JSON value to the jq expression as a variable. Format: name=json. Example: --jq-argjson 'limit=100'")
+
+	params.jqLibPath = reg.Strings(
+		"jq-library-path",
+		[]string{"~/.jq"},
+		"Paths to search jq modules imported by jq expressions, definitions in the ~/.jq file are available in all expressions")
 
 	params.include = reg.StringsP(
 		"include",
@@ -162,11 +266,63 @@ func defineFlags(reg *config.Registry, params *filterParams) {
 		nil,
 		"Exclude records that match any of the specified regular expressions")
 
+	params.where = reg.StringArray(
+		"where",
+		nil,
+		"Include only records whose fields match all the specified conditions.\n"+
+			"Format: 'path=value', 'path:substring' or 'path~regexp' optionally followed by 'case=true' and 'word=true' (not for '=').\n"+
+			"Nested properties are separated by dots. Example: --where \"msg~'timeout.*db' word=true\"")
+
+	params.whereNot = reg.StringArray(
+		"where-not",
+		nil,
+		"Exclude records whose fields match any of the specified conditions. Format is the same as for --where")
+
+	params.around = reg.String(
+		"around",
+		"",
+		"Include only records within the time window around the specified time or record.\n"+
+			"Format: 'TIME±WINDOW' or 'FILE:RNUM±WINDOW'. Examples: '2024-03-01T10:00:00Z±30s', 'worker.log:1234 1m'")
+
+	params.recRanges = reg.Strings(
+		"range",
+		nil,
+		"Include only records with numbers (rnum) in the specified inclusive ranges. Format: 'FROM-TO', 'FROM-' or 'FILE:FROM-TO' where FILE is an input file.\n"+
+			"Records outside the ranges are skipped before parsing, reading stops after the ranges. Example: --range worker.log:10400-10650")
+
+	params.patternIDs = reg.Strings(
+		"pattern",
+		nil,
+		"Include only records whose message matches the patterns with the specified ids printed by --patterns.\n"+
+			"The patterns are mined again from the input files with the other filters applied, so use the same filters as for --patterns")
+
+	params.queries = reg.StringArray(
+		"query",
+		nil,
+		"Apply a named query defined in the configuration file. Format: 'name [param=value ...]'.\n"+
+			"Use 'logex queries' to list available queries and macros")
+
 	params.durationMs = reg.Strings(
 		"duration-ms",
 		nil,
 		"Treat specified fields as duration strings and convert them to milliseconds (useful for filtering)")
 
+	params.asDuration = reg.Strings(
+		"as-duration",
+		nil,
+		"Convert duration strings of the specified fields to numbers. Format: field[:unit], unit is ns, us, ms, s, m or h (default ms)")
+
+	params.asBytes = reg.Strings(
+		"as-bytes",
+		nil,
+		"Convert size strings like '512KiB' or '1.2GB' of the specified fields to numbers. Format: field[:unit], "+
+			"\nunit is B, KB, KiB, MB, MiB, GB, GiB, TB, TiB (default B), KB = 1000 B and KiB = 1024 B")
+
+	params.asNumber = reg.Strings(
+		"as-number",
+		nil,
+		"Convert numbers and percentages logged as strings (\"42\", \"87%\") of the specified fields to numbers")
+
 	params.selectProps = reg.Strings(
 		"select",
 		nil,
@@ -182,11 +338,38 @@ func defineFlags(reg *config.Registry, params *filterParams) {
 		nil,
 		"Parse property names with string values as JSON objects for use in filters and other operations")
 
+	params.steps = reg.StringArray(
+		"step",
+		nil,
+		"Add a registered step to the pipeline. Format: 'name [param=value ...]'. Can be repeated.\n"+
+			"Stateful steps like distinct-by are applied after the files are merged.\n"+
+			"Use 'logex steps' to list available steps and their parameters")
+
 	params.showErrors = reg.Bool(
 		"show-errors",
 		false,
 		"Show processing errors")
 
+	params.stats = reg.Bool(
+		"stats",
+		false,
+		"Print statistics of each pipeline step to stderr: records in, out, removed, errors and time spent")
+
+	params.explainPlan = reg.Bool(
+		"explain-plan",
+		false,
+		"Print the assembled pipeline steps without reading input")
+
+	params.showRemoved = reg.Bool(
+		"show-removed",
+		false,
+		"Show records removed by filters dimmed together with the removal reason")
+
+	params.why = reg.String(
+		"why",
+		"",
+		"Explain why the specified record was filtered out. Format: 'rnum=N [file=NAME]'")
+
 	params.headProps = reg.StringsP(
 		"txt-head",
 		"t",
@@ -220,16 +403,156 @@ func defineFlags(reg *config.Registry, params *filterParams) {
 		"text",
 		"Output format, can be \"text\" or \"json\"")
 
-	params.distinctBy = reg.String(
+	params.outputs = reg.StringArray(
+		"output",
+		nil,
+		"Write records to an additional output. Format: 'format:path [option=value ...]', path '-' means stdout.\n"+
+			"Options for the text format: txt-head, order, txt-nonl, txt-noprop, txt-delim, highlight, group-separator.\n"+
+			"Can be repeated. Example: --output json:matched.jsonl --output 'text:out.txt txt-noprop=true'")
+
+	params.distinctBy = reg.Strings(
 		"distinct-by",
+		nil,
+		"Return distinct records based on the specified property names, nested properties are separated by dots.\n"+
+			"Records missing a property are deduplicated together as having the same missing value")
+
+	params.distinctKeep = reg.String(
+		"distinct-keep",
+		"first",
+		"Which of the records with the same --distinct-by values to keep, \"first\" or \"last\". The last records are printed after all records are read")
+
+	params.distinctCount = reg.Bool(
+		"distinct-count",
+		false,
+		"Add the 'count' field with the number of records with the same --distinct-by values and 'first_seen' and 'last_seen' fields\n"+
+			"with their timestamps. Records are printed after all records are read")
+
+	params.distinctPrefix = reg.String(
+		"distinct-count-prefix",
+		"",
+		"Prefix of the --distinct-count field names, for example 'distinct_' to keep the 'count' field of the records")
+
+	params.distinctLimit = reg.Int(
+		"distinct-limit",
+		1000000,
+		"Maximum number of --distinct-by keys to remember, records with new keys are printed as they come without deduplication\n"+
+			"with a warning when exceeded")
+
+	params.collapse = reg.Bool(
+		"collapse",
+		false,
+		"Collapse consecutive repeated records into the first one with the 'repeated' field containing the number of occurrences\n"+
+			"and 'first_seen' and 'last_seen' fields with their timestamps")
+
+	params.collapseBy = reg.Strings(
+		"collapse-by",
+		[]string{"level", "msg"},
+		"Properties identifying repeated records for --collapse")
+
+	params.collapseWindow = reg.String(
+		"collapse-window",
 		"",
-		"Return distinct records based on the specified property names")
+		"Also collapse non-consecutive repeats within the time window after the first occurrence, for example 10s. Implies --collapse")
+
+	params.pairBy = reg.Strings(
+		"pair-by",
+		nil,
+		"Match start and end records of operations with the same values of the properties, for example job_id.\n"+
+			"The end record is replaced by the start record with the 'end', 'start_time', 'end_time' and 'duration_ms' fields,\n"+
+			"starts without end records are printed at the end with the 'unmatched' and 'age_ms' fields. Requires --pair-start and --pair-end")
+
+	params.pairStart = reg.String(
+		"pair-start",
+		"",
+		"KQL condition of the start records for --pair-by. Example: 'msg:\"job started\"'")
+
+	params.pairEnd = reg.String(
+		"pair-end",
+		"",
+		"KQL condition of the end records for --pair-by. Example: 'msg:\"job finished\"'")
+
+	params.pairTimeout = reg.String(
+		"pair-timeout",
+		"",
+		"Report starts without end records older than the timeout at the end of the stream as lost instead of running, for example 1h")
+
+	params.sample = reg.String(
+		"sample",
+		"",
+		"Keep only a sample of the filtered records. Format: 'RATE [by=field] [seed=N] [field=name]' or 'every=N [by=field] [field=name]'.\n"+
+			"RATE is a fraction or a percentage. With 'by' the records are sampled by the hash of the field value, so records with\n"+
+			"the same value are either all kept or all removed. 'field' adds the sampling rate to records. Example: --sample '1% by=trace_id'")
 
 	params.mergeBy = reg.Strings(
 		"merge",
 		[]string{"ts"},
 		"Merge multiple files into single stream of records by specified fields (usually by timestamp)")
 
+	params.timeFields = reg.Strings(
+		"time-fields",
+		steps.DefaultTimeFields,
+		"Fields containing record timestamps, the first found field is used")
+
+	params.patterns = reg.Bool(
+		"patterns",
+		false,
+		"Print message templates with variable parts (numbers, ids, IP addresses, quoted strings) masked instead of records,\n"+
+			"sorted by the number of records with an example record each")
+
+	params.patternField = reg.String(
+		"pattern-field",
+		"msg",
+		"Property containing the message for --patterns and --pattern")
+
+	params.levelField = reg.String(
+		"level-field",
+		"level",
+		"Property containing the record level")
+
+	params.baseline = reg.Strings(
+		"baseline",
+		nil,
+		"Compare message templates of the input files with the templates of the specified baseline files (filtered the same way)\n"+
+			"and print templates which are new, disappeared or changed significantly in frequency or level")
+
+	params.histogram = reg.String(
+		"histogram",
+		"",
+		"Print the number of records in time intervals of the specified duration as a bar chart instead of records, for example 1m.\n"+
+			"With --format json buckets are printed as records")
+
+	params.histogramBy = reg.String(
+		"histogram-by",
+		"",
+		"Split --histogram bars by the values of the specified property, for example level")
+
+	params.histogramWidth = reg.Int(
+		"histogram-width",
+		60,
+		"Maximum length of --histogram bars")
+
+	params.histogramASCII = reg.Bool(
+		"histogram-ascii",
+		false,
+		"Draw --histogram bars with ASCII characters")
+
+	params.top = reg.Strings(
+		"top",
+		nil,
+		"Print the most frequent values of the specified properties with their counts and percentages of records instead of records.\n"+
+			"Nested properties are separated by dots, every element of arrays is counted")
+
+	params.topN = reg.Int(
+		"top-n",
+		10,
+		"Number of values printed by --top, 0 prints all values")
+
+	params.topApprox = reg.Int(
+		"top-approx",
+		0,
+		"Count --top values approximately keeping at most the specified number of values in memory, for properties with\n"+
+			"a very large number of distinct values. The most frequent values are found, their counts may be overestimated by the printed error")
+
 	params.highlights = reg.StringsP(
 		"highlight",
 		"l",
@@ -254,6 +577,39 @@ func defineFlags(reg *config.Registry, params *filterParams) {
 		"Print N additional records before and after matches",
 	)
 
+	params.before = reg.IntP(
+		"before",
+		"B",
+		0,
+		"Print N additional records before matches, overrides --context")
+
+	params.after = reg.IntP(
+		"after",
+		"A",
+		0,
+		"Print N additional records after matches, overrides --context")
+
+	params.groupSeparator = reg.String(
+		"group-separator",
+		"--",
+		"Line printed between non-adjacent groups of context records in the text format, empty value disables it")
+
+	params.contextBy = reg.String(
+		"context-by",
+		"",
+		"Print all records having the same value of the specified property (for example trace_id) as a matched record,\n"+
+			"grouped together. Groups are printed after all records are read")
+
+	params.contextByLimit = reg.Int(
+		"context-by-limit",
+		100000,
+		"Maximum number of records buffered for --context-by before the first match of their group, older records are dropped")
+
+	params.contextByMax = reg.Int(
+		"context-by-max-groups",
+		100000,
+		"Maximum number of --context-by groups to buffer, records of new groups are printed as they come with a warning when exceeded")
+
 	params.metadata = reg.StringP(
 		"metadata",
 		"m",
@@ -272,15 +628,200 @@ func (p *filterParams) Validate() error {
 	default:
 		return fmt.Errorf("Unknown output format: %s", f)
 	}
+	if k := p.distinctKeep(); k != "first" && k != "last" {
+		return fmt.Errorf("invalid --distinct-keep value %q, expected \"first\" or \"last\"", k)
+	}
+	if (p.contextBefore() > 0 || p.contextAfter() > 0) && len(p.contextBy()) > 0 {
+		return fmt.Errorf("--context, --before and --after can not be used together with --context-by")
+	}
+	if _, err := parseRanges(p.recRanges(), p.fileNames); err != nil {
+		return err
+	}
+	if (p.contextBefore() > 0 || p.contextAfter() > 0) && (p.showRemoved() || len(p.why()) > 0) {
+		// context records are output as removed ones
+		return fmt.Errorf("--context, --before and --after can not be used together with --show-removed and --why")
+	}
+	if (len(p.pairStart()) > 0 || len(p.pairEnd()) > 0) && len(p.pairBy()) == 0 {
+		return fmt.Errorf("--pair-start and --pair-end require --pair-by")
+	}
+
+	// each of these flags prints its own summary instead of the records
+	var summaries []string
+	if len(p.baseline()) > 0 {
+		summaries = append(summaries, "--baseline")
+	}
+	if p.patterns() {
+		summaries = append(summaries, "--patterns")
+	}
+	if len(p.histogram()) > 0 {
+		summaries = append(summaries, "--histogram")
+	}
+	if len(p.top()) > 0 {
+		summaries = append(summaries, "--top")
+	}
+	if len(summaries) > 1 {
+		return fmt.Errorf("%s can not be used together", strings.Join(summaries, ", "))
+	}
+	if len(p.baseline()) > 0 && len(p.patternIDs()) > 0 {
+		// the templates of the baseline and of the target files are mined together
+		return fmt.Errorf("--pattern can not be used together with --baseline")
+	}
 	return nil
 }
 
+// summaryFlags replace the records printed by the root command, subcommands print their own results
+var summaryFlags = []string{
+	"explain-plan", "baseline", "patterns", "histogram", "histogram-by", "histogram-width", "histogram-ascii",
+	"top", "top-n", "top-approx", "show-removed", "why",
+}
+
+// recordOutputFlags configure writing of the records
+var recordOutputFlags = []string{
+	"output", "txt-head", "order", "txt-nonl", "txt-noprop", "txt-delim", "highlight", "group-separator",
+}
+
+// rejectFlags hides the root flags which a subcommand does not use and fails the subcommand when they are set.
+func rejectFlags(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		cmd.Flags().MarkHidden(name)
+	}
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		for _, name := range names {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s is not supported by the %s command", name, cmd.Name())
+			}
+		}
+		return nil
+	}
+}
+
+// reportError prints a processing error skipped by a subcommand if --show-errors is set.
+func (p *filterParams) reportError(errW io.Writer, err error) {
+	if p.showErrors() {
+		fmt.Fprintln(errW, err)
+	}
+}
+
+func (p *filterParams) jqEnv() (steps.JqEnv, error) {
+	env := steps.JqEnv{
+		Vars:         make(map[string]any),
+		LibraryPaths: p.jqLibPath(),
+	}
+
+	for _, arg := range p.jqArgs() {
+		name, value, found := strings.Cut(arg, "=")
+		if !found {
+			return env, fmt.Errorf("invalid --jq-arg %q, expected name=value", arg)
+		}
+		env.Vars[name] = value
+	}
+
+	for _, arg := range p.jqArgsJSON() {
+		name, value, found := strings.Cut(arg, "=")
+		if !found {
+			return env, fmt.Errorf("invalid --jq-argjson %q, expected name=json", arg)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return env, fmt.Errorf("invalid --jq-argjson %s value: %w", name, err)
+		}
+		env.Vars[name] = v
+	}
+
+	return env, nil
+}
+
+func (p *filterParams) contextBefore() int {
+	if p.before() > 0 {
+		return p.before()
+	}
+	return p.context()
+}
+
+func (p *filterParams) contextAfter() int {
+	if p.after() > 0 {
+		return p.after()
+	}
+	return p.context()
+}
+
+func (p *filterParams) conversions() map[string]string {
+	res := make(map[string]string)
+	for prop, cfg := range p.propertiesConfig {
+		if len(cfg.Convert) > 0 {
+			res[prop] = cfg.Convert
+		}
+	}
+
+	add := func(kind string, fields []string) {
+		for _, f := range fields {
+			field, unit, found := strings.Cut(f, ":")
+			if found {
+				res[field] = kind + ":" + unit
+			} else {
+				res[field] = kind
+			}
+		}
+	}
+	for _, f := range p.durationMs() {
+		res[f] = "duration-ms"
+	}
+	add("duration", p.asDuration())
+	add("bytes", p.asBytes())
+	add("number", p.asNumber())
+	return res
+}
+
 func doFilter(params *filterParams, cmd *cobra.Command) error {
 	if err := params.Validate(); err != nil {
 		return err
 	}
 
-	input := lo.Map(params.fileNames, func(fileName string, _ int) fileDescr {
+	if params.explainPlan() {
+		return runPipeline(params, nil, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	}
+
+	if len(params.baseline()) > 0 {
+		return compareWithBaseline(params, cmd)
+	}
+
+	if params.patterns() {
+		params.consume = func(records pipeline.Seq[steps.JSON]) error {
+			return printPatterns(cmd.OutOrStdout(), params.outputFormat(), minePatterns(params, records))
+		}
+	}
+
+	if len(params.histogram()) > 0 {
+		params.consume = func(records pipeline.Seq[steps.JSON]) error {
+			h, err := buildHistogram(params, records, cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			return printHistogram(cmd.OutOrStdout(), params, h, colors.DefaultColorBuilder)
+		}
+	}
+
+	if len(params.top()) > 0 {
+		params.consume = func(records pipeline.Seq[steps.JSON]) error {
+			return printTop(cmd.OutOrStdout(), params.outputFormat(), params.topN(), countTop(params, records))
+		}
+	}
+
+	if err := applyPatternIDs(params, cmd); err != nil {
+		return err
+	}
+
+	input, closeInput, err := openInput(params.fileNames, cmd)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	return runPipeline(params, input, cmd.OutOrStdout(), cmd.ErrOrStderr())
+}
+
+func openInput(fileNames []string, cmd *cobra.Command) ([]fileDescr, func(), error) {
+	input := lo.Map(fileNames, func(fileName string, _ int) fileDescr {
 		var reader io.Reader
 		var close func() error
 		var err error
@@ -301,103 +842,373 @@ func doFilter(params *filterParams, cmd *cobra.Command) error {
 			close:    close}
 	})
 
-	for _, fd := range input {
-		if fd.err != nil {
-			return fd.err
+	closeInput := func() {
+		for _, fd := range input {
+			if fd.err == nil {
+				fd.close()
+			}
 		}
 	}
 
-	defer func() {
-		for _, fd := range input {
-			fd.close()
+	for _, fd := range input {
+		if fd.err != nil {
+			closeInput()
+			return nil, nil, fd.err
 		}
-	}()
+	}
 
-	return runPipeline(params, input, cmd.OutOrStdout())
+	return input, closeInput, nil
 }
 
-func runPipeline(params *filterParams, input []fileDescr, w io.Writer) error {
+func runPipeline(params *filterParams, input []fileDescr, w, errW io.Writer) error {
 	opts := pipeline.PipelineOptions{
-		ContextEnabled: params.context() > 0,
+		ContextEnabled: params.contextBefore() > 0 || params.contextAfter() > 0 || len(params.contextBy()) > 0,
+		Warn:           func(msg string) { fmt.Fprintln(errW, "warning:", msg) },
+	}
+	if params.stats() || params.explainPlan() {
+		opts.Plan = pipeline.NewPlan()
 	}
-	filterByKQL, err := steps.FilterByKQL(opts, params.kqlFilter())
+
+	why, err := parseWhy(params.why())
 	if err != nil {
 		return err
 	}
+	opts.KeepRemoved = params.showRemoved() || why.Int("rnum") >= 0
+
+	isStringStep := func(spec string, _ int) bool {
+		return steps.StringSteps.Has(spec)
+	}
+	stringSpecs := lo.Filter(params.steps(), isStringStep)
+	jsonSpecs := lo.Reject(params.steps(), isStringStep)
+	isStatefulStep := func(spec string, _ int) bool {
+		return steps.JSONSteps.IsStateful(spec)
+	}
+	statefulSpecs := lo.Filter(jsonSpecs, isStatefulStep)
+	jsonSpecs = lo.Reject(jsonSpecs, isStatefulStep)
 
-	filterByJq, err := steps.FilterByJq(opts, params.jq())
+	recRanges, err := parseRanges(params.recRanges(), params.fileNames)
 	if err != nil {
 		return err
 	}
-
-	addMeta, err := steps.AddMeta(opts, params.metadata())
+	filterByRange := steps.FilterByRange(opts.Named("range"), recRanges)
+	removePrefix := steps.RemovePrefix(opts.Named("remove-prefix"))
+	exclude := steps.ExcludeSubstringsAny(opts.Named("exclude"), params.exclude())
+	include := steps.IncludeSubstringsAny(opts.Named("include"), params.include())
+	includeRegexp, err := steps.IncludeRegexp(opts.Named("include-regexp"), params.includeRegexp())
+	if err != nil {
+		return err
+	}
+	excludeRegexp, err := steps.ExcludeRegexp(opts.Named("exclude-regexp"), params.excludeRegexp())
+	if err != nil {
+		return err
+	}
+	customString, err := parseSteps(opts, steps.StringSteps, stringSpecs)
 	if err != nil {
 		return err
 	}
 
-	var formatJSONToText pipeline.Step[steps.JSON, string]
+	strToJson := steps.StrToJson(opts.Named("parse-json"))
 
-	if len(params.headProps()) > 0 || params.outputFormat() == "text" {
-		formatJSONToText, err = steps.JsonToText(
-			opts,
-			params.headProps(),
-			params.orderProps(),
-			params.textNoNewLine(),
-			params.textNoProp(),
-			params.textDelim(),
-			slices.Concat(params.include(), params.highlights()),
-			params.propertiesConfig)
-		if err != nil {
-			return err
-		}
-	} else {
-		formatJSONToText = steps.JsonToStr(opts)
+	addMeta, err := steps.AddMeta(opts.Named("metadata"), params.metadata())
+	if err != nil {
+		return err
+	}
+	expand, err := steps.JSONSteps.Create(opts, "expand", pipeline.Params{"props": params.expandProps()})
+	if err != nil {
+		return err
+	}
+	convert, err := steps.Convert(opts.Named("convert"), params.conversions())
+	if err != nil {
+		return err
+	}
+	where, err := steps.Where(opts.Named("where"), params.where())
+	if err != nil {
+		return err
 	}
+	whereNot, err := steps.WhereNot(opts.Named("where-not"), params.whereNot())
+	if err != nil {
+		return err
+	}
+	aroundFrom, aroundTo, err := parseAround(params.around(), params.fileNames, params.timeFields())
+	if err != nil {
+		return err
+	}
+	around := steps.Around(opts.Named("around"), params.timeFields(), aroundFrom, aroundTo)
+	kql, err := params.macrosConfig.Expand(params.kqlFilter())
+	if err != nil {
+		return err
+	}
+	filterByKQL, err := steps.FilterByKQL(opts.Named("kql"), kql, params.timeFields())
+	if err != nil {
+		return err
+	}
+	jqEnv, err := params.jqEnv()
+	if err != nil {
+		return err
+	}
+	filterByJq, err := steps.FilterByJq(opts.Named("jq"), params.jq(), jqEnv)
+	if err != nil {
+		return err
+	}
+	customJSON, err := parseSteps(opts, steps.JSONSteps, jsonSpecs)
+	if err != nil {
+		return err
+	}
+	customStateful, err := parseSteps(opts, steps.JSONSteps, statefulSpecs)
+	if err != nil {
+		return err
+	}
+	includePatterns := steps.IncludePatterns(opts.Named("pattern"), params.patternField(), params.patternTemplates)
+	hide, err := steps.JSONSteps.Create(opts, "hide", pipeline.Params{"props": params.hideProps()})
+	if err != nil {
+		return err
+	}
+	selectProps, err := steps.JSONSteps.Create(opts, "select", pipeline.Params{"props": params.selectProps()})
+	if err != nil {
+		return err
+	}
+	context := steps.Context(opts.Named("context"), params.contextBefore(), params.contextAfter())
 
-	includeRegexp, err := steps.IncludeRegexp(opts, params.includeRegexp())
+	contextBy := steps.ContextBy(opts.Named("context-by"), params.contextBy(), params.contextByLimit(), params.contextByMax())
+	pair, err := createPair(opts, params)
+	if err != nil {
+		return err
+	}
+	distinctBy := steps.DistinctBy(opts.Named("distinct-by"), steps.DistinctConfig{
+		Fields:      params.distinctBy(),
+		KeepLast:    params.distinctKeep() == "last",
+		Count:       params.distinctCount(),
+		CountPrefix: params.distinctPrefix(),
+		TimeProps:   params.timeFields(),
+		MaxKeys:     params.distinctLimit(),
+		Warn:        opts.Warn,
+	})
+	collapse, err := createCollapse(opts, params)
 	if err != nil {
 		return err
 	}
-	excludeRegexp, err := steps.ExcludeRegexp(opts, params.excludeRegexp())
+	sample, err := parseSample(opts, params.sample())
 	if err != nil {
 		return err
 	}
+	first := steps.First(opts.Named("first"), params.first())
+	last := steps.Last(opts.Named("last"), params.last())
+	filterByRecNum := steps.FilterByRecNum(opts.Named("why"), why.Int("rnum"), why.String("file"))
+	jqSlurp, err := steps.SlurpJq(opts.Named("jq-slurp"), params.jqSlurp(), jqEnv)
+	if err != nil {
+		return err
+	}
+
+	var sinks []steps.Sink
+	if params.consume == nil {
+		var closeSinks func()
+		sinks, closeSinks, err = createSinks(opts, params, w, params.explainPlan())
+		if err != nil {
+			return err
+		}
+		defer closeSinks()
+	}
+
+	if params.explainPlan() {
+		return printPlan(w, opts.Plan)
+	}
 
 	processStringInput := pipeline.Combine(
-		steps.RemovePrefix(opts),
-		steps.ExcludeSubstringsAny(opts, params.exclude()),
-		steps.IncludeSubstringsAny(opts, params.include()),
+		filterByRange,
+		removePrefix,
+		exclude,
+		include,
 		includeRegexp,
 		excludeRegexp,
+		customString,
 	)
 
 	processJSON := pipeline.Combine(
 		addMeta,
-		steps.Expand(opts, params.expandProps()),
+		expand,
+		convert,
+		where,
+		whereNot,
+		around,
 		filterByKQL,
 		filterByJq,
-		steps.Hide(opts, params.hideProps()),
-		steps.Select(opts, params.selectProps()),
-		steps.Context(opts, params.context(), params.context()),
+		customJSON,
+		includePatterns,
+		hide,
+		selectProps,
+		context,
 	)
 
 	postProcessJSON := pipeline.Combine(
-		steps.DistinctBy(opts, params.distinctBy()),
-		steps.First(opts, params.first()),
-		steps.Last(opts, params.last()),
+		customStateful,
+		contextBy,
+		pair,
+		distinctBy,
+		collapse,
+		sample,
+		first,
+		last,
+		filterByRecNum,
+		jqSlurp,
 	)
 
 	multiJsons := lo.Map(input, func(f fileDescr, _ int) pipeline.Seq[steps.JSON] {
 		return processJSON(
-			steps.StrToJson(opts, params.durationMs())(
+			strToJson(
 				processStringInput(steps.ReadByLines(f.fileName, f.r))))
 	})
 
 	mergedJsons := steps.Merge(opts, params.mergeBy(), multiJsons)
 
-	return steps.WriteLines(
-		w,
-		params.showErrors(),
-		formatJSONToText(
-			postProcessJSON(mergedJsons)))
+	records := postProcessJSON(mergedJsons)
+	if params.consume != nil {
+		err = params.consume(records)
+	} else {
+		err = steps.WriteSinks(sinks, records)
+	}
+	if err != nil {
+		return err
+	}
+
+	if params.stats() {
+		return printStats(errW, opts.Plan)
+	}
+	return nil
+}
+
+func parseSteps[T any](
+	opts pipeline.PipelineOptions,
+	registry *pipeline.Registry[T, T],
+	specs []string) (pipeline.Step[T, T], error) {
+	res := make([]pipeline.Step[T, T], 0, len(specs))
+	for _, spec := range specs {
+		step, err := registry.Parse(opts, spec)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, step)
+	}
+
+	return pipeline.Combine(res...), nil
+}
+
+// namedNoop returns a noop step listed in the plan under the name of the step it replaces.
+func namedNoop(opts pipeline.PipelineOptions, name string) pipeline.Step[steps.JSON, steps.JSON] {
+	opts.Named(name)
+	return steps.Noop[steps.JSON]()
+}
+
+func createPair(opts pipeline.PipelineOptions, params *filterParams) (pipeline.Step[steps.JSON, steps.JSON], error) {
+	if len(params.pairBy()) == 0 {
+		return namedNoop(opts, "pair"), nil
+	}
+
+	var timeout time.Duration
+	if t := params.pairTimeout(); len(t) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return nil, fmt.Errorf("invalid --pair-timeout: %w", err)
+		}
+	}
+	start, err := params.macrosConfig.Expand(params.pairStart())
+	if err != nil {
+		return nil, err
+	}
+	end, err := params.macrosConfig.Expand(params.pairEnd())
+	if err != nil {
+		return nil, err
+	}
+	return steps.Pair(opts.Named("pair"), steps.PairConfig{
+		Fields:    params.pairBy(),
+		Start:     start,
+		End:       end,
+		TimeProps: params.timeFields(),
+		Timeout:   timeout,
+		Warn:      opts.Warn,
+	})
+}
+
+func createCollapse(opts pipeline.PipelineOptions, params *filterParams) (pipeline.Step[steps.JSON, steps.JSON], error) {
+	if !params.collapse() && len(params.collapseWindow()) == 0 {
+		return namedNoop(opts, "collapse"), nil
+	}
+
+	return steps.JSONSteps.Create(opts, "collapse", pipeline.Params{
+		"by":          params.collapseBy(),
+		"window":      params.collapseWindow(),
+		"time-fields": params.timeFields(),
+	})
+}
+
+func parseSample(opts pipeline.PipelineOptions, spec string) (pipeline.Step[steps.JSON, steps.JSON], error) {
+	fields, err := pipeline.SplitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return namedNoop(opts, "sample"), nil
+	}
+	if !strings.Contains(fields[0], "=") {
+		fields[0] = "rate=" + fields[0]
+	}
+
+	def, _ := steps.JSONSteps.Lookup("sample")
+	sampleParams, err := pipeline.ParseParams(def.Params, fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sample value: %w", err)
+	}
+	return steps.JSONSteps.Create(opts, "sample", sampleParams)
+}
+
+// parseRanges parses the range specifications, file names of the ranges are replaced by the matching input file names.
+func parseRanges(specs []string, fileNames []string) ([]steps.RecRange, error) {
+	res := make([]steps.RecRange, len(specs))
+	for i, spec := range specs {
+		r, err := steps.ParseRange(spec)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.FileName) > 0 {
+			idx := slices.IndexFunc(fileNames, func(f string) bool {
+				if f == "-" {
+					return r.FileName == "-" || r.FileName == "stdin"
+				}
+				return filepath.Clean(f) == filepath.Clean(r.FileName)
+			})
+			if idx < 0 {
+				return nil, fmt.Errorf("invalid range %q: %s is not an input file", spec, r.FileName)
+			}
+			r.FileName = fileNames[idx]
+			if r.FileName == "-" {
+				r.FileName = "stdin"
+			}
+		}
+		res[i] = r
+	}
+	return res, nil
+}
+
+var whyParams = []pipeline.ParamDef{
+	{Name: "rnum", Type: pipeline.ParamInt},
+	{Name: "file", Type: pipeline.ParamString},
+}
+
+func parseWhy(spec string) (pipeline.Params, error) {
+	if len(spec) == 0 {
+		return pipeline.Params{"rnum": -1}, nil
+	}
+
+	args, err := pipeline.SplitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	why, err := pipeline.ParseParams(whyParams, args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --why value: %w", err)
+	}
+	if _, ok := why["rnum"]; !ok {
+		return nil, fmt.Errorf("invalid --why value: rnum is required")
+	}
+	return why, nil
 }
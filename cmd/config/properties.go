@@ -4,6 +4,8 @@ type (
 	Properties map[string]Property
 	Property   struct {
 		Colors
+		// Convert is a conversion of the property value: duration[:unit], bytes[:unit] or number
+		Convert string
 	}
 	Colors []Color
 	Color  struct {
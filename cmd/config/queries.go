@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type (
+	Queries map[string]Query
+	Query   struct {
+		Description   string   `koanf:"description"`
+		Params        []string `koanf:"params"`
+		KQL           string   `koanf:"kql"`
+		Jq            string   `koanf:"jq"`
+		Include       []string `koanf:"include"`
+		Exclude       []string `koanf:"exclude"`
+		IncludeRegexp []string `koanf:"include-regexp"`
+		ExcludeRegexp []string `koanf:"exclude-regexp"`
+		Where         []string `koanf:"where"`
+		WhereNot      []string `koanf:"where-not"`
+	}
+
+	Macros map[string]Macro
+	Macro  struct {
+		Description string   `koanf:"description"`
+		Params      []string `koanf:"params"`
+		KQL         string   `koanf:"kql"`
+	}
+)
+
+// Bind substitutes ${param} placeholders with the argument values.
+// Parameters are declared as 'name' or 'name=default'.
+func (q Query) Bind(args map[string]string) (Query, error) {
+	r, err := newParamsReplacer(q.Params, args)
+	if err != nil {
+		return Query{}, err
+	}
+
+	replaceAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		res := make([]string, len(values))
+		for i, v := range values {
+			res[i] = r.Replace(v)
+		}
+		return res
+	}
+
+	q.KQL = r.Replace(q.KQL)
+	q.Jq = r.Replace(q.Jq)
+	q.Include = replaceAll(q.Include)
+	q.Exclude = replaceAll(q.Exclude)
+	q.IncludeRegexp = replaceAll(q.IncludeRegexp)
+	q.ExcludeRegexp = replaceAll(q.ExcludeRegexp)
+	q.Where = replaceAll(q.Where)
+	q.WhereNot = replaceAll(q.WhereNot)
+	return q, nil
+}
+
+func newParamsReplacer(params []string, args map[string]string) (*strings.Replacer, error) {
+	var oldNew []string
+	declared := make(map[string]struct{}, len(params))
+	for _, p := range params {
+		name, dflt, hasDefault := strings.Cut(p, "=")
+		declared[name] = struct{}{}
+		value, ok := args[name]
+		if !ok {
+			if !hasDefault {
+				return nil, fmt.Errorf("parameter %s is required", name)
+			}
+			value = dflt
+		}
+		oldNew = append(oldNew, "${"+name+"}", value)
+	}
+
+	for name := range args {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	return strings.NewReplacer(oldNew...), nil
+}
+
+var macroRegexp = regexp.MustCompile(`'[^']*'|"[^"]*"|@([A-Za-z_][\w-]*)(?:\(([^)]*)\))?`)
+
+// Expand replaces macro invocations in a KQL expression: @name or @name(arg1, arg2).
+// Unknown names are kept as is, so fields like @timestamp are not affected.
+func (m Macros) Expand(kql string) (string, error) {
+	return m.expand(kql, 0)
+}
+
+func (m Macros) expand(kql string, depth int) (string, error) {
+	if len(m) == 0 {
+		return kql, nil
+	}
+	if depth > 10 {
+		return "", fmt.Errorf("macros nesting is too deep: %s", kql)
+	}
+
+	var expandErr error
+	res := macroRegexp.ReplaceAllStringFunc(kql, func(s string) string {
+		groups := macroRegexp.FindStringSubmatch(s)
+		macro, ok := m[groups[1]]
+		if len(groups[1]) == 0 || !ok {
+			return s
+		}
+
+		args := make(map[string]string)
+		if len(groups[2]) > 0 {
+			values := strings.Split(groups[2], ",")
+			if len(values) > len(macro.Params) {
+				expandErr = fmt.Errorf("macro %s: too many arguments", groups[1])
+				return s
+			}
+			for i, v := range values {
+				name, _, _ := strings.Cut(macro.Params[i], "=")
+				args[name] = strings.TrimSpace(v)
+			}
+		}
+
+		r, err := newParamsReplacer(macro.Params, args)
+		if err != nil {
+			expandErr = fmt.Errorf("macro %s: %w", groups[1], err)
+			return s
+		}
+
+		expanded, err := m.expand(r.Replace(macro.KQL), depth+1)
+		if err != nil {
+			expandErr = err
+			return s
+		}
+		return "(" + expanded + ")"
+	})
+
+	return res, expandErr
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMacrosExpand(t *testing.T) {
+	macros := Macros{
+		"slowdb":  {KQL: "component:db and duration > ${threshold}", Params: []string{"threshold=1000"}},
+		"svc":     {KQL: "service:${name}", Params: []string{"name"}},
+		"payment": {KQL: "@svc(payment) and level:error"},
+	}
+
+	checkExpand(t, macros, "@slowdb", "(component:db and duration > 1000)")
+	checkExpand(t, macros, "@slowdb(500) or level:error", "(component:db and duration > 500) or level:error")
+	checkExpand(t, macros, "@payment", "((service:payment) and level:error)")
+	checkExpand(t, macros, "@timestamp > 1 and user:'@slowdb'", "@timestamp > 1 and user:'@slowdb'")
+
+	_, err := macros.Expand("@svc")
+	assert.ErrorContains(t, err, "parameter name is required")
+
+	_, err = macros.Expand("@svc(a, b)")
+	assert.ErrorContains(t, err, "too many arguments")
+}
+
+func TestQueryBind(t *testing.T) {
+	q := Query{
+		Params: []string{"level=error", "service"},
+		KQL:    "level:${level} and service:${service}",
+		Where:  []string{"msg:${service}"},
+	}
+
+	bound, err := q.Bind(map[string]string{"service": "payment"})
+	require.NoError(t, err)
+	assert.Equal(t, "level:error and service:payment", bound.KQL)
+	assert.Equal(t, []string{"msg:payment"}, bound.Where)
+
+	_, err = q.Bind(map[string]string{"service": "payment", "foo": "bar"})
+	assert.ErrorContains(t, err, "unknown parameter foo")
+}
+
+func checkExpand(t *testing.T, macros Macros, kql, expected string) {
+	t.Helper()
+	res, err := macros.Expand(kql)
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+}
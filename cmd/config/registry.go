@@ -101,6 +101,16 @@ func (r *Registry) Strings(name string, defaultValue []string, help string) func
 	)
 }
 
+func (r *Registry) StringArray(name string, defaultValue []string, help string) func() []string {
+	return defineParam(
+		name,
+		defaultValue,
+		help,
+		r.fs.StringArray,
+		r.k.Strings,
+	)
+}
+
 func defineParam[T any](
 	name string,
 	defaultValue T,
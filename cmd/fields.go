@@ -0,0 +1,312 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+type fieldsParams struct {
+	perFile       func() bool
+	examples      func() int
+	starterConfig func() bool
+}
+
+func createFieldsCmd() *cobra.Command {
+	var params filterParams
+	var fields fieldsParams
+	var k = koanf.New(".")
+
+	fieldsCmd := &cobra.Command{
+		Use:   "fields [flags] file-name",
+		Short: "List properties of filtered records with their types, presence, number of distinct values and examples",
+		Example: "  logex fields app.log\n" +
+			"  logex fields --per-file -f 'level:error' app.log worker.log\n" +
+			"  logex fields --starter-config app.log > logex.yaml",
+		Run: func(cmd *cobra.Command, args []string) {
+			params.fileNames = args
+			params.config, _ = cmd.Flags().GetString("config")
+			err := loadConfiguration(&params, k, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = doFields(&params, &fields, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	reg := config.NewRegistry(k, fieldsCmd.Flags())
+	defineFlags(reg, &params)
+	rejectFlags(fieldsCmd, append(summaryFlags, recordOutputFlags...)...)
+
+	fields.perFile = reg.Bool(
+		"per-file",
+		false,
+		"Report properties of each input file separately")
+	fields.examples = reg.Int(
+		"examples",
+		3,
+		"Number of example values of each property")
+	fields.starterConfig = reg.Bool(
+		"starter-config",
+		false,
+		"Print a starter YAML configuration with head properties, level colors and conversions of the properties of all files")
+	// the starter configuration is built from the properties of all files
+	fieldsCmd.MarkFlagsMutuallyExclusive("per-file", "starter-config")
+
+	return fieldsCmd
+}
+
+// fileInventory is the inventory of the properties of a file, or of all files when the name is empty.
+type fileInventory struct {
+	fileName  string
+	inventory *steps.FieldInventory
+}
+
+func doFields(params *filterParams, fields *fieldsParams, cmd *cobra.Command) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := applyPatternIDs(params, cmd); err != nil {
+		return err
+	}
+
+	var inventories []*fileInventory
+	params.consume = func(records pipeline.Seq[steps.JSON]) error {
+		for rec, err := range records {
+			if err != nil {
+				params.reportError(cmd.ErrOrStderr(), err)
+				continue
+			}
+			if rec.Metadata.Removed {
+				continue
+			}
+			fileName := ""
+			if fields.perFile() {
+				fileName = rec.Metadata.FileName
+			}
+			idx := slices.IndexFunc(inventories, func(f *fileInventory) bool { return f.fileName == fileName })
+			if idx < 0 {
+				idx = len(inventories)
+				inventories = append(inventories, &fileInventory{fileName, steps.NewFieldInventory(fields.examples())})
+			}
+			inventories[idx].inventory.Add(rec.Value)
+		}
+		return nil
+	}
+	if !cmd.Flags().Changed("metadata") {
+		// the default metadata fields are not a part of the logs
+		params.metadata = func() string { return "" }
+	}
+
+	input, closeInput, err := openInput(params.fileNames, cmd)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	if err := runPipeline(params, input, io.Discard, cmd.ErrOrStderr()); err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if fields.starterConfig() {
+		all := steps.NewFieldInventory(0)
+		if len(inventories) == 1 {
+			all = inventories[0].inventory
+		}
+		return printStarterConfig(w, params, all)
+	}
+	if params.outputFormat() == "json" {
+		return printFieldsJSON(w, inventories)
+	}
+	return printFields(w, inventories)
+}
+
+type fieldOutput struct {
+	File       string   `json:"file,omitempty"`
+	Field      string   `json:"field"`
+	Types      []string `json:"types"`
+	Count      int      `json:"count"`
+	Percent    float64  `json:"percent"`
+	Distinct   int      `json:"distinct"`
+	Estimated  bool     `json:"estimated,omitempty"`
+	Examples   []any    `json:"examples"`
+	Conversion string   `json:"convert,omitempty"`
+}
+
+func fieldPercent(inv *steps.FieldInventory, count int) float64 {
+	return math.Round(float64(count)*1000/float64(inv.Records())) / 10
+}
+
+func printFieldsJSON(w io.Writer, inventories []*fileInventory) error {
+	enc := json.NewEncoder(w)
+	for _, fi := range inventories {
+		for _, f := range fi.inventory.Fields() {
+			err := enc.Encode(fieldOutput{
+				File:       fi.fileName,
+				Field:      f.Path,
+				Types:      f.Types,
+				Count:      f.Count,
+				Percent:    fieldPercent(fi.inventory, f.Count),
+				Distinct:   f.Distinct,
+				Estimated:  !f.DistinctExact,
+				Examples:   f.Examples,
+				Conversion: f.Conversion,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func printFields(w io.Writer, inventories []*fileInventory) error {
+	for i, fi := range inventories {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		name := fi.fileName
+		if len(name) == 0 {
+			name = "all files"
+		}
+		fmt.Fprintf(w, "%s: %d records\n", name, fi.inventory.Records())
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "field\ttypes\tpresent\tdistinct\texamples")
+		for _, f := range fi.inventory.Fields() {
+			distinct := fmt.Sprint(f.Distinct)
+			if f.Distinct == 0 {
+				// objects and arrays have no scalar values
+				distinct = "-"
+			} else if !f.DistinctExact {
+				distinct = "~" + distinct
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%s\t%s\n",
+				f.Path,
+				strings.Join(f.Types, ","),
+				fieldPercent(fi.inventory, f.Count),
+				distinct,
+				formatExamples(f.Examples))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatExamples(examples []any) string {
+	res := make([]string, len(examples))
+	for i, e := range examples {
+		b, _ := json.Marshal(e)
+		s := strings.ReplaceAll(string(b), "\t", " ")
+		if r := []rune(s); len(r) > 40 {
+			s = string(r[:37]) + "..."
+		}
+		res[i] = s
+	}
+	return strings.Join(res, ", ")
+}
+
+var levelColors = map[string]config.PColor{
+	"fatal":    config.PColorRed,
+	"panic":    config.PColorRed,
+	"critical": config.PColorRed,
+	"error":    config.PColorRed,
+	"err":      config.PColorRed,
+	"warn":     config.PColorYellow,
+	"warning":  config.PColorYellow,
+	"info":     config.PColorBlue,
+}
+
+// printStarterConfig prints a configuration with the time, level and message properties as head properties,
+// colors of the found level values and conversions of string properties with numbers, durations or sizes.
+func printStarterConfig(w io.Writer, params *filterParams, inv *steps.FieldInventory) error {
+	fields := inv.Fields()
+	present := func(path string) (steps.FieldInfo, bool) {
+		idx := slices.IndexFunc(fields, func(f steps.FieldInfo) bool { return f.Path == path })
+		if idx < 0 || fields[idx].Count*2 < inv.Records() {
+			return steps.FieldInfo{}, false
+		}
+		return fields[idx], true
+	}
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# starter configuration generated by 'logex fields' from %d records\n", inv.Records())
+
+	var head []string
+	for _, tf := range params.timeFields() {
+		if _, ok := present(tf); ok {
+			fmt.Fprintf(&sb, "time-fields: [%s]\n", quote(tf))
+			head = append(head, quote(tf))
+			break
+		}
+	}
+	level, hasLevel := present(params.levelField())
+	if hasLevel {
+		head = append(head, quote(level.Path))
+	}
+	if msg, ok := present(params.patternField()); ok {
+		head = append(head, quote(msg.Path))
+	}
+	if len(head) > 0 {
+		fmt.Fprintf(&sb, "txt-head: [%s]\n", strings.Join(head, ", "))
+	}
+
+	sb.WriteString("properties:\n")
+	properties := 0
+	if hasLevel {
+		var colors strings.Builder
+		for _, v := range level.Values {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			if c, ok := levelColors[strings.ToLower(s)]; ok {
+				fmt.Fprintf(&colors, "      - value: %s\n        color: %s\n", quote(s), c)
+			}
+		}
+		if colors.Len() > 0 {
+			fmt.Fprintf(&sb, "  %s:\n    colors:\n%s", quote(level.Path), colors.String())
+			properties++
+		}
+	}
+	for _, f := range fields {
+		if len(f.Conversion) == 0 {
+			continue
+		}
+		conversion := f.Conversion
+		if conversion == "duration" {
+			conversion += ":ms"
+		}
+		fmt.Fprintf(&sb, "  %s:\n    convert: %s\n", quote(f.Path), conversion)
+		properties++
+	}
+	if properties == 0 {
+		sb.WriteString("  {}\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
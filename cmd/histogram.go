@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladimir-rom/logex/colors"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+// partial blocks are used for the fraction of the last cell of bars without splitting
+var partialBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+func buildHistogram(params *filterParams, records pipeline.Seq[steps.JSON], errW io.Writer) (*steps.Histogram, error) {
+	interval, err := time.ParseDuration(params.histogram())
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid --histogram interval %q", params.histogram())
+	}
+
+	h := steps.NewHistogram(interval, params.timeFields(), params.histogramBy())
+	for rec, err := range records {
+		if err != nil || rec.Metadata.Removed {
+			continue
+		}
+		h.Add(rec.Value)
+	}
+	if h.Skipped() > 0 {
+		fmt.Fprintf(errW, "warning: %d records without timestamps in %v are skipped\n", h.Skipped(), params.timeFields())
+	}
+	return h, nil
+}
+
+type histogramOutput struct {
+	Time   string         `json:"time"`
+	Count  int            `json:"count"`
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+func printHistogram(w io.Writer, params *filterParams, h *steps.Histogram, colorBuilder colors.ColorBuilder) error {
+	buckets, err := h.Buckets()
+	if err != nil {
+		return err
+	}
+	interval, _ := time.ParseDuration(params.histogram())
+	layout := time.RFC3339
+	if interval%time.Second != 0 {
+		layout = time.RFC3339Nano
+	}
+
+	if params.outputFormat() == "json" {
+		enc := json.NewEncoder(w)
+		for _, b := range buckets {
+			if err := enc.Encode(histogramOutput{Time: b.Start.Format(layout), Count: b.Count, Counts: b.Counts}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	c, err := colors.NewColorizer(params.propertiesConfig, colorBuilder)
+	if err != nil {
+		return err
+	}
+	splitBy := params.histogramBy()
+	values := h.Values()
+	valueColors := make(map[string]colors.StrColorizer, len(values))
+	for _, v := range values {
+		col, ok := c.ValueColor(splitBy, v)
+		if !ok {
+			// values without configured colors are not colored unless they are levels
+			col = func(s string) string { return s }
+			if splitBy == params.levelField() {
+				col = c.LevelColor(v)
+			}
+		}
+		valueColors[v] = col
+	}
+
+	full := "█"
+	if params.histogramASCII() {
+		full = "#"
+	}
+	width := max(params.histogramWidth(), 1)
+
+	if len(values) > 0 {
+		legend := make([]string, len(values))
+		for i, v := range values {
+			legend[i] = valueColors[v](full) + " " + v
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(legend, "  ")); err != nil {
+			return err
+		}
+	}
+
+	maxCount := 0
+	for _, b := range buckets {
+		maxCount = max(maxCount, b.Count)
+	}
+	countWidth := len(strconv.Itoa(maxCount))
+
+	for _, b := range buckets {
+		var bar strings.Builder
+		cells := 0
+		if len(values) == 0 || b.Count == 0 {
+			eighths := int(math.Round(float64(b.Count) * float64(width*8) / float64(maxCount)))
+			if b.Count > 0 {
+				eighths = max(eighths, 1)
+			}
+			cells = eighths / 8
+			bar.WriteString(strings.Repeat(full, cells))
+			if rest := eighths % 8; rest > 0 {
+				if params.histogramASCII() {
+					bar.WriteString(full)
+				} else {
+					bar.WriteString(partialBlocks[rest])
+				}
+				cells++
+			}
+		} else {
+			// segments are rounded by cumulative counts, so the bar length is proportional to the total
+			cumulative := 0
+			for _, v := range values {
+				n := b.Counts[v]
+				if n == 0 {
+					continue
+				}
+				start := int(math.Round(float64(cumulative) * float64(width) / float64(maxCount)))
+				cumulative += n
+				end := int(math.Round(float64(cumulative) * float64(width) / float64(maxCount)))
+				if end == start && end == cells && end < width {
+					// keep rare values visible
+					end++
+				}
+				if end > cells {
+					bar.WriteString(valueColors[v](strings.Repeat(full, end-cells)))
+					cells = end
+				}
+			}
+		}
+
+		line := fmt.Sprintf("%s  %s%s  %*d", b.Start.Format(layout), bar.String(), strings.Repeat(" ", max(width-cells, 0)), countWidth, b.Count)
+		if len(values) > 0 && b.Count > 0 {
+			counts := make([]string, 0, len(b.Counts))
+			for _, v := range values {
+				if n := b.Counts[v]; n > 0 {
+					counts = append(counts, v+":"+strconv.Itoa(n))
+				}
+			}
+			line += "  " + strings.Join(counts, " ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,12 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/patterns"
+	"github.com/vladimir-rom/logex/pipeline"
 	"github.com/vladimir-rom/logex/steps"
 )
 
@@ -48,6 +55,63 @@ func TestKQL(t *testing.T) {
 		[]steps.JSON{{"field": "value2"}})
 }
 
+func TestKQLTime(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-03-01T09:59:59Z", "msg": "m1"},
+		{"ts": "2024-03-01 12:00:30+02:00", "msg": "m2"},
+		{"ts": float64(1709287260), "msg": "m3"},
+		{"ts": "2024-03-02T00:00:00Z", "msg": "m4"},
+		{"msg": "m5"},
+	}
+
+	testCmd(t,
+		[]string{"-f", "ts >= 2024-03-01T10:00:00Z and ts < '2024-03-01T10:01:01Z'"},
+		input,
+		[]steps.JSON{input[1], input[2]})
+
+	testCmd(t,
+		[]string{"-f", "ts <= 2024-03-01"},
+		input,
+		[]steps.JSON{input[0], input[1], input[2]})
+
+	testCmd(t,
+		[]string{"-f", "ts > now-1h or msg:m4"},
+		input,
+		[]steps.JSON{input[3]})
+
+	testCmd(t,
+		[]string{"-f", "time > 2024-03-01", "--time-fields", "time"},
+		[]steps.JSON{{"time": "2024-03-02T00:00:00Z"}, {"time": "2024-03-01T23:59:59Z"}},
+		[]steps.JSON{{"time": "2024-03-02T00:00:00Z"}})
+}
+
+func TestDistinctBy(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "1", "user": "alice", "path": "/a"},
+		{"ts": "2", "user": "bob", "path": "/a"},
+		{"ts": "3", "user": "alice", "path": "/a"},
+		{"ts": "4", "user": "alice", "path": "/b"},
+	}
+
+	testCmd(t,
+		[]string{"--distinct-by", "user,path"},
+		input,
+		[]steps.JSON{input[0], input[1], input[3]})
+
+	testCmd(t,
+		[]string{"--distinct-by", "user", "--distinct-keep", "last", "--distinct-count"},
+		input,
+		[]steps.JSON{
+			{"ts": "2", "user": "bob", "path": "/a", "count": float64(1), "first_seen": "2", "last_seen": "2"},
+			{"ts": "4", "user": "alice", "path": "/b", "count": float64(3), "first_seen": "1", "last_seen": "4"},
+		})
+
+	testCmd(t,
+		[]string{"--distinct-by", "path", "--distinct-count", "--distinct-count-prefix", "distinct_", "--time-fields", "ts"},
+		[]steps.JSON{{"ts": "1", "path": "/a", "count": 5.0}, {"ts": "2", "path": "/a", "count": 6.0}},
+		[]steps.JSON{{"ts": "1", "path": "/a", "count": 5.0, "distinct_count": 2.0, "distinct_first_seen": "1", "distinct_last_seen": "2"}})
+}
+
 func TestHide(t *testing.T) {
 	testCmd(t,
 		[]string{"--hide", "field2"},
@@ -110,6 +174,122 @@ func TestExcludeRegexp(t *testing.T) {
 		[]steps.JSON{{"field": "value3"}})
 }
 
+func TestWhere(t *testing.T) {
+	input := []steps.JSON{
+		{"msg": "db timeout", "user": "errorbot"},
+		{"msg": "error: DB timeout", "user": "alice"},
+		{"msg": "timeouts", "user": "bob", "ctx": map[string]any{"tags": []any{"a", "b"}}},
+	}
+
+	testCmd(t,
+		[]string{"--where", "msg:error"},
+		input,
+		[]steps.JSON{input[1]})
+
+	testCmd(t,
+		[]string{"--where", "msg~timeout word=true", "--where-not", "user=ALICE"},
+		input,
+		[]steps.JSON{input[0]})
+
+	testCmd(t,
+		[]string{"--where", "msg:DB case=true"},
+		input,
+		[]steps.JSON{input[1]})
+
+	testCmd(t,
+		[]string{"--where", "ctx.tags=b"},
+		input,
+		[]steps.JSON{input[2]})
+}
+
+func TestConversions(t *testing.T) {
+	testCmd(t,
+		[]string{"--duration-ms", "d", "-f", "d > 1000"},
+		[]steps.JSON{{"d": "1s"}, {"d": "1.5009s"}},
+		[]steps.JSON{{"d": 1500.0}})
+
+	testCmd(t,
+		[]string{"--as-bytes", "mem.used:KiB", "--as-number", "cpu", "-f", "mem.used >= 512 and cpu > 50"},
+		[]steps.JSON{
+			{"mem": map[string]any{"used": "512KiB"}, "cpu": "87%"},
+			{"mem": map[string]any{"used": "1KiB"}, "cpu": "97%"},
+		},
+		[]steps.JSON{{"mem": map[string]any{"used": 512.0}, "cpu": 87.0}})
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("properties:\n  size:\n    convert: bytes:MB\n"), 0o600))
+	testCmd(t,
+		[]string{"--config", configFile},
+		[]steps.JSON{{"size": "1.2GB"}},
+		[]steps.JSON{{"size": 1200.0}})
+}
+
+func TestAround(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-03-01T10:00:00Z", "msg": "m1"},
+		{"ts": "2024-03-01T10:00:40Z", "msg": "m2"},
+		{"ts": "2024-03-01T10:01:00Z", "msg": "m3"},
+		{"ts": "2024-03-01T10:02:00Z", "msg": "m4"},
+	}
+
+	testCmd(t,
+		[]string{"--around", "2024-03-01T10:00:50Z±30s"},
+		input,
+		[]steps.JSON{input[1], input[2]})
+
+	fileName := filepath.Join(t.TempDir(), "other.log")
+	require.NoError(t, os.WriteFile(fileName, []byte(
+		`{"ts": "2024-03-01T10:00:30Z", "msg": "o1"}`+"\n"+
+			`{"ts": "2024-03-01T10:01:55Z", "msg": "o2"}`+"\n"), 0o600))
+
+	testCmd(t,
+		[]string{"--around", fileName + ":1 10s", fileName},
+		input,
+		[]steps.JSON{{"ts": "2024-03-01T10:01:55Z", "msg": "o2"}, input[3]})
+}
+
+func TestQueries(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+queries:
+  errors:
+    description: errors of a service
+    params: [service]
+    kql: "@error and @svc(${service})"
+macros:
+  svc:
+    params: [name]
+    kql: service:${name}
+  error: level:error
+  slow:
+    params: [threshold=1000]
+    kql: duration > ${threshold}
+`), 0o600))
+	t.Setenv("LOGEX_CONFIG", configFile)
+
+	input := []steps.JSON{
+		{"level": "error", "service": "payment", "duration": 100},
+		{"level": "info", "service": "payment", "duration": 2000},
+		{"level": "error", "service": "auth", "duration": 3000},
+	}
+	testCmd(t,
+		[]string{"--query", "errors service=payment"},
+		input,
+		[]steps.JSON{{"level": "error", "service": "payment", "duration": 100.0}})
+
+	testCmd(t,
+		[]string{"-f", "@slow"},
+		input,
+		[]steps.JSON{
+			{"level": "info", "service": "payment", "duration": 2000.0},
+			{"level": "error", "service": "auth", "duration": 3000.0}})
+
+	testCmd(t,
+		[]string{"--query", "errors service=auth", "-f", "@slow(2500)"},
+		input,
+		[]steps.JSON{{"level": "error", "service": "auth", "duration": 3000.0}})
+}
+
 func TestContext(t *testing.T) {
 	input := make([]steps.JSON, 0)
 	for i := range 5 {
@@ -119,27 +299,57 @@ func TestContext(t *testing.T) {
 	testCmd(t,
 		[]string{"--include", "Value3", "--context", "1"},
 		input,
-		[]steps.JSON{{"field": "value2"}, {"field": "value3"}, {"field": "value4"}})
+		[]steps.JSON{{"field": "value2", "context": true}, {"field": "value3"}, {"field": "value4", "context": true}})
 
 	testCmd(t,
 		[]string{"--include", "Value1", "--context", "1"},
 		input,
-		[]steps.JSON{{"field": "value1"}, {"field": "value2"}})
+		[]steps.JSON{{"field": "value1"}, {"field": "value2", "context": true}})
 
 	testCmd(t,
 		[]string{"--include", "Value5", "--context", "1"},
 		input,
-		[]steps.JSON{{"field": "value4"}, {"field": "value5"}})
+		[]steps.JSON{{"field": "value4", "context": true}, {"field": "value5"}})
 
 	testCmd(t,
 		[]string{"--include", "Value3", "--context", "5"},
 		input,
-		[]steps.JSON{{"field": "value1"}, {"field": "value2"}, {"field": "value3"}, {"field": "value4"}, {"field": "value5"}})
+		[]steps.JSON{
+			{"field": "value1", "context": true},
+			{"field": "value2", "context": true},
+			{"field": "value3"},
+			{"field": "value4", "context": true},
+			{"field": "value5", "context": true}})
 
 	testCmd(t,
 		[]string{"--include", "Value1111", "--context", "1"},
 		input,
 		[]steps.JSON{})
+
+	testCmd(t,
+		[]string{"--include", "Value3", "-B", "2", "-A", "0"},
+		input,
+		[]steps.JSON{{"field": "value1", "context": true}, {"field": "value2", "context": true}, {"field": "value3"}})
+
+	testCmd(t,
+		[]string{"--include", "Value2", "--after", "1"},
+		input,
+		[]steps.JSON{{"field": "value2"}, {"field": "value3", "context": true}})
+}
+
+func TestContextText(t *testing.T) {
+	input := make([]steps.JSON, 0)
+	for i := range 6 {
+		input = append(input, steps.JSON{"msg": fmt.Sprintf("value%d", i+1)})
+	}
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-", "--include", "value2", "--include", "value5", "-A", "1", "--metadata", "", "--txt-head", "msg", "--txt-noprop", "--txt-nonl"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "> value2\n  value3\n--\n> value5\n  value6\n", out.String())
 }
 
 func TestMetadata(t *testing.T) {
@@ -159,6 +369,726 @@ func TestMetadata(t *testing.T) {
 		[]steps.JSON{{"field": "value2", "rnum": 1.0}})
 }
 
+func TestStep(t *testing.T) {
+	testCmd(t,
+		[]string{"--step", "hide props=field2,field3"},
+		[]steps.JSON{{"field1": "value1", "field2": "value2", "field3": "value3"}},
+		[]steps.JSON{{"field1": "value1"}})
+
+	testCmd(t,
+		[]string{"--step", "kql filter='field:value2 or field:value3'", "--step", "exclude substrings=value3"},
+		[]steps.JSON{{"field": "value1"}, {"field": "value2"}, {"field": "value3"}},
+		[]steps.JSON{{"field": "value2"}})
+}
+
+func TestStatefulStepAfterMerge(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "b.log")
+	require.NoError(t, os.WriteFile(fileName, []byte(
+		`{"ts": "2024-03-01T10:00:01Z", "user": "bob"}`+"\n"+
+			`{"ts": "2024-03-01T10:00:03Z", "user": "dan"}`+"\n"), 0o600))
+
+	testCmd(t,
+		[]string{"--step", "distinct-by prop=user keep=last", fileName},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:00Z", "user": "bob"},
+			{"ts": "2024-03-01T10:00:02Z", "user": "dan"},
+			{"ts": "2024-03-01T10:00:04Z", "user": "alice"},
+		},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:01Z", "user": "bob"},
+			{"ts": "2024-03-01T10:00:03Z", "user": "dan"},
+			{"ts": "2024-03-01T10:00:04Z", "user": "alice"},
+		})
+}
+
+func TestContextFiles(t *testing.T) {
+	// files are processed concurrently, run with -race to check that the context state is not shared
+	fileName := filepath.Join(t.TempDir(), "b.log")
+	require.NoError(t, os.WriteFile(fileName, []byte(
+		`{"ts": "2024-03-01T10:00:01Z", "msg": "b1"}`+"\n"+
+			`{"ts": "2024-03-01T10:00:03Z", "msg": "b2 hit"}`+"\n"+
+			`{"ts": "2024-03-01T10:00:05Z", "msg": "b3"}`+"\n"+
+			`{"ts": "2024-03-01T10:00:07Z", "msg": "b4"}`+"\n"), 0o600))
+
+	testCmd(t,
+		[]string{"--include", "hit", "--context", "1", fileName},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:00Z", "msg": "a1"},
+			{"ts": "2024-03-01T10:00:02Z", "msg": "a2"},
+			{"ts": "2024-03-01T10:00:04Z", "msg": "a3"},
+			{"ts": "2024-03-01T10:00:06Z", "msg": "a4 hit"},
+		},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:01Z", "msg": "b1", "context": true},
+			{"ts": "2024-03-01T10:00:03Z", "msg": "b2 hit"},
+			{"ts": "2024-03-01T10:00:04Z", "msg": "a3", "context": true},
+			{"ts": "2024-03-01T10:00:05Z", "msg": "b3", "context": true},
+			{"ts": "2024-03-01T10:00:06Z", "msg": "a4 hit"},
+		})
+
+	params := parseFilterParams(t, []string{"--context", "1", "--show-removed"})
+	assert.EqualError(t, params.Validate(), "--context, --before and --after can not be used together with --show-removed and --why")
+}
+
+func TestStepWarnings(t *testing.T) {
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-", "--step", "distinct-by prop=user limit=1", "--metadata", ""})
+	cmd.SetIn(marshalJson(t, []steps.JSON{{"user": "alice"}, {"user": "bob"}}))
+	cmd.SetOut(&bytes.Buffer{})
+	errOut := bytes.Buffer{}
+	cmd.SetErr(&errOut)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, errOut.String(), "warning: distinct-by: more than 1 distinct keys")
+}
+
+func TestCustomStep(t *testing.T) {
+	steps.JSONSteps.Register(pipeline.StepDef[steps.JSON, steps.JSON]{
+		Name: "test-set",
+		Params: []pipeline.ParamDef{
+			{Name: "prop", Type: pipeline.ParamString},
+			{Name: "value", Type: pipeline.ParamString, Default: "default"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[steps.JSON, steps.JSON], error) {
+			return pipeline.NewStep(opts, func(obj pipeline.Item[steps.JSON], yield pipeline.Yield[steps.JSON]) bool {
+				obj.Value[p.String("prop")] = p.String("value")
+				return yield(obj, nil)
+			}), nil
+		},
+	})
+
+	testCmd(t,
+		[]string{"--step", "test-set prop=field2"},
+		[]steps.JSON{{"field1": "value1"}},
+		[]steps.JSON{{"field1": "value1", "field2": "default"}})
+
+	_, err := parseSteps(pipeline.PipelineOptions{}, steps.JSONSteps, []string{"test-set foo=bar"})
+	assert.ErrorContains(t, err, "unknown parameter foo")
+}
+
+func TestOutput(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "out.jsonl")
+	textFile := filepath.Join(dir, "out.txt")
+
+	testCmd(t,
+		[]string{
+			"-f", "field:value2",
+			"--output", "json:" + jsonFile,
+			"--output", "text:" + textFile + " txt-head=field txt-noprop=true txt-nonl=true"},
+		[]steps.JSON{{"field": "value1"}, {"field": "value2"}},
+		[]steps.JSON{{"field": "value2"}})
+
+	f, err := os.Open(jsonFile)
+	require.NoError(t, err)
+	defer f.Close()
+	checkOutput(t, []steps.JSON{{"field": "value2"}}, f)
+
+	text, err := os.ReadFile(textFile)
+	require.NoError(t, err)
+	assert.Equal(t, "value2\n", string(text))
+}
+
+func TestStats(t *testing.T) {
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-f", "field:value2", "--stats", "--metadata", "", "-"})
+	cmd.SetIn(marshalJson(t, []steps.JSON{{"field": "value1"}, {"field": "value2"}}))
+	outBuffer := bytes.Buffer{}
+	errBuffer := bytes.Buffer{}
+	cmd.SetOut(&outBuffer)
+	cmd.SetErr(&errBuffer)
+	require.NoError(t, cmd.Execute())
+
+	assert.Regexp(t, `(?m)^kql\s+2\s+1\s+1\s+0\s`, errBuffer.String())
+	assert.NotContains(t, errBuffer.String(), "jq")
+}
+
+func TestStatsCmd(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-03-01T10:00:10Z", "service": "api", "level": "error", "duration": 100},
+		{"ts": "2024-03-01T10:00:50Z", "service": "api", "level": "error", "duration": 300},
+		{"ts": "2024-03-01T10:01:10Z", "service": "db", "level": "error", "duration": 50},
+		{"ts": "2024-03-01T10:01:20Z", "service": "api", "level": "info", "duration": 20},
+	}
+
+	testCmd(t,
+		[]string{"stats", "--by", "service,ts/1m", "-f", "level:error"},
+		input,
+		[]steps.JSON{
+			{"service": "api", "ts": "2024-03-01T10:00:00Z", "count": 2.0},
+			{"service": "db", "ts": "2024-03-01T10:01:00Z", "count": 1.0},
+		})
+
+	testCmd(t,
+		[]string{"stats", "--by", "service", "--agg", "count,max(duration)", "--sort", "max_duration", "--desc"},
+		input,
+		[]steps.JSON{
+			{"service": "api", "count": 3.0, "max_duration": 300.0},
+			{"service": "db", "count": 1.0, "max_duration": 50.0},
+		})
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"stats", "-", "--by", "level", "--agg", "count,avg(duration),p50(duration)"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"level  count  avg_duration  p50_duration  \n"+
+			"error  3      150           100           \n"+
+			"info   1      20            20            \n",
+		out.String())
+}
+
+func TestHistogram(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-03-01T10:00:10Z", "level": "error"},
+		{"ts": "2024-03-01T10:00:50Z", "level": "info"},
+		{"ts": "2024-03-01T10:00:55Z", "level": "info"},
+		{"ts": "2024-03-01T10:02:10Z", "level": "info"},
+	}
+
+	testCmd(t,
+		[]string{"--histogram", "1m", "--histogram-by", "level"},
+		input,
+		[]steps.JSON{
+			{"time": "2024-03-01T10:00:00Z", "count": 3.0, "counts": map[string]any{"error": 1.0, "info": 2.0}},
+			{"time": "2024-03-01T10:01:00Z", "count": 0.0},
+			{"time": "2024-03-01T10:02:00Z", "count": 1.0, "counts": map[string]any{"info": 1.0}},
+		})
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-", "--histogram", "1m", "--histogram-ascii", "--histogram-width", "6", "-f", "level:info"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"2024-03-01T10:00:00Z  ######  2\n"+
+			"2024-03-01T10:01:00Z          0\n"+
+			"2024-03-01T10:02:00Z  ###     1\n",
+		out.String())
+
+	input = []steps.JSON{
+		{"ts": "2024-03-01T10:00:10Z", "user": "alice"},
+		{"ts": "2024-03-01T10:00:50Z", "user": "bob"},
+		{"ts": "2024-03-01T10:01:10Z", "user": "alice"},
+	}
+	cmd = createRootCmd()
+	cmd.SetArgs([]string{"-", "--histogram", "1m", "--histogram-by", "user", "--histogram-ascii", "--histogram-width", "4"})
+	cmd.SetIn(marshalJson(t, input))
+	out = bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"# alice  # bob\n"+
+			"2024-03-01T10:00:00Z  ####  2  alice:1 bob:1\n"+
+			"2024-03-01T10:01:00Z  ##    1  alice:1\n",
+		out.String())
+}
+
+func TestTop(t *testing.T) {
+	input := []steps.JSON{
+		{"user": "alice", "level": "error", "tags": []any{"db", "retry"}},
+		{"user": "bob", "level": "error", "tags": []any{"db"}},
+		{"user": "alice", "level": "error"},
+		{"level": "error"},
+		{"user": "carol", "level": "info"},
+	}
+
+	testCmd(t,
+		[]string{"--top", "user,tags", "--top-n", "2", "-f", "level:error"},
+		input,
+		[]steps.JSON{
+			{"field": "user", "value": "alice", "count": 2.0, "percent": 50.0},
+			{"field": "user", "value": "bob", "count": 1.0, "percent": 25.0},
+			{"field": "tags", "value": "db", "count": 2.0, "percent": 50.0},
+			{"field": "tags", "value": "retry", "count": 1.0, "percent": 25.0},
+		})
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-", "--top", "user"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"user (5 records, 1 without the property)\n"+
+			"  alice  2   40.0%\n"+
+			"  bob    1   20.0%\n"+
+			"  carol  1   20.0%\n",
+		out.String())
+}
+
+func TestFields(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-01-01T10:00:00Z", "level": "info", "msg": "started", "dur": "5ms"},
+		{"ts": "2024-01-01T10:00:01Z", "level": "error", "msg": "failed", "dur": "15ms", "req": steps.JSON{"id": 1}},
+	}
+
+	testCmd(t,
+		[]string{"fields", "--examples", "1", "-f", "not msg:failed"},
+		input,
+		[]steps.JSON{
+			{"field": "dur", "types": []any{"string"}, "count": 1.0, "percent": 100.0, "distinct": 1.0, "examples": []any{"5ms"}, "convert": "duration"},
+			{"field": "level", "types": []any{"string"}, "count": 1.0, "percent": 100.0, "distinct": 1.0, "examples": []any{"info"}},
+			{"field": "msg", "types": []any{"string"}, "count": 1.0, "percent": 100.0, "distinct": 1.0, "examples": []any{"started"}},
+			{"field": "ts", "types": []any{"string"}, "count": 1.0, "percent": 100.0, "distinct": 1.0, "examples": []any{"2024-01-01T10:00:00Z"}},
+		})
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"fields", "-", "--examples", "2"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"all files: 2 records\n"+
+			"field   types   present  distinct  examples\n"+
+			"dur     string  100.0%   2         \"5ms\", \"15ms\"\n"+
+			"level   string  100.0%   2         \"info\", \"error\"\n"+
+			"msg     string  100.0%   2         \"started\", \"failed\"\n"+
+			"ts      string  100.0%   2         \"2024-01-01T10:00:00Z\", \"2024-01-01T10:00:01Z\"\n"+
+			"req     object  50.0%    -         \n"+
+			"req.id  number  50.0%    1         1\n",
+		out.String())
+
+	cmd = createRootCmd()
+	cmd.SetArgs([]string{"fields", "-", "--starter-config"})
+	cmd.SetIn(marshalJson(t, input))
+	out = bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"# starter configuration generated by 'logex fields' from 2 records\n"+
+			"time-fields: [\"ts\"]\n"+
+			"txt-head: [\"ts\", \"level\", \"msg\"]\n"+
+			"properties:\n"+
+			"  \"level\":\n"+
+			"    colors:\n"+
+			"      - value: \"info\"\n"+
+			"        color: blue\n"+
+			"      - value: \"error\"\n"+
+			"        color: red\n"+
+			"  \"dur\":\n"+
+			"    convert: duration:ms\n",
+		out.String())
+
+	cmd = createRootCmd()
+	cmd.SetArgs([]string{"fields", "-", "--starter-config", "--per-file"})
+	cmd.SetIn(marshalJson(t, input))
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	assert.ErrorContains(t, cmd.Execute(), "[per-file starter-config] were all set")
+}
+
+func TestTrace(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-03-01T10:00:00Z", "trace_id": "abc", "span_id": "1", "name": "GET /orders", "duration": 1000},
+		{"ts": "2024-03-01T10:00:00.250Z", "trace_id": "abc", "span_id": "2", "parent_span_id": "1", "name": "auth", "duration": "250ms"},
+		{"ts": "2024-03-01T10:00:00.500Z", "trace_id": "abc", "span_id": "3", "parent_span_id": "1", "name": "query", "duration": "500ms"},
+		{"ts": "2024-03-01T10:00:00.600Z", "trace_id": "abc", "span_id": "4", "parent_span_id": "3", "msg": "retry"},
+		{"ts": "2024-03-01T10:00:00Z", "msg": "no trace"},
+	}
+
+	testCmd(t,
+		[]string{"trace", "-f", "not name:auth"},
+		input,
+		[]steps.JSON{
+			{"trace_id": "abc", "span_id": "1", "depth": 0.0, "name": "GET /orders", "start": "2024-03-01T10:00:00Z", "offset_ms": 0.0, "duration_ms": 1000.0},
+			{"trace_id": "abc", "span_id": "3", "parent_id": "1", "depth": 1.0, "name": "query", "start": "2024-03-01T10:00:00.5Z", "offset_ms": 500.0, "duration_ms": 500.0},
+			{"trace_id": "abc", "span_id": "4", "parent_id": "3", "depth": 2.0, "name": "retry", "start": "2024-03-01T10:00:00.6Z", "offset_ms": 600.0, "duration_ms": nil},
+		})
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"trace", "-", "--width", "8", "--ascii"})
+	cmd.SetIn(marshalJson(t, input))
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"trace abc  2024-03-01T10:00:00Z  4 spans  1s\n"+
+			"GET /orders     0s     1s  ########\n"+
+			"|- auth      250ms  250ms    ##\n"+
+			"`- query     500ms  500ms      ####\n"+
+			"   `- retry  600ms      ?      |\n",
+		out.String())
+}
+
+func TestSubcommandFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"stats", "-", "--top", "user"},
+		{"fields", "-", "--explain-plan"},
+		{"trace", "-", "--output", "json:-"},
+	} {
+		cmd := createRootCmd()
+		cmd.SetArgs(args)
+		cmd.SetIn(marshalJson(t, []steps.JSON{{"user": "alice"}}))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		assert.EqualError(t, cmd.Execute(), fmt.Sprintf("%s is not supported by the %s command", args[2], args[0]))
+	}
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"stats", "--help"})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "--output")
+	assert.NotContains(t, out.String(), "--histogram")
+}
+
+func TestPair(t *testing.T) {
+	testCmd(t,
+		[]string{"--pair-by", "job_id", "--pair-start", "msg:started", "--pair-end", "msg:finished", "-f", "not msg:progress"},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:00Z", "msg": "started", "job_id": 1},
+			{"ts": "2024-03-01T10:00:01Z", "msg": "started", "job_id": 2},
+			{"ts": "2024-03-01T10:00:01.5Z", "msg": "progress", "job_id": 2},
+			{"ts": "2024-03-01T10:00:02Z", "msg": "finished", "job_id": 1},
+		},
+		[]steps.JSON{
+			{
+				"ts": "2024-03-01T10:00:00Z", "msg": "started", "job_id": 1.0,
+				"end":        map[string]any{"ts": "2024-03-01T10:00:02Z", "msg": "finished", "job_id": 1.0},
+				"start_time": "2024-03-01T10:00:00Z", "end_time": "2024-03-01T10:00:02Z", "duration_ms": 2000.0,
+			},
+			{"ts": "2024-03-01T10:00:01Z", "msg": "started", "job_id": 2.0, "unmatched": "running", "age_ms": 1000.0},
+		})
+
+	testCmd(t,
+		[]string{"--pair-by", "job_id", "--pair-start", "msg:started", "--pair-end", "msg:finished", "--show-removed"},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:00Z", "msg": "started", "job_id": 1},
+			{"ts": "2024-03-01T10:00:01Z", "msg": "started", "job_id": 2},
+			{"ts": "2024-03-01T10:00:02Z", "msg": "finished", "job_id": 1},
+		},
+		[]steps.JSON{
+			{"ts": "2024-03-01T10:00:00Z", "msg": "started", "job_id": 1.0, "removed": "pair: start of a pair with job_id:1"},
+			{
+				"ts": "2024-03-01T10:00:00Z", "msg": "started", "job_id": 1.0,
+				"end":        map[string]any{"ts": "2024-03-01T10:00:02Z", "msg": "finished", "job_id": 1.0},
+				"start_time": "2024-03-01T10:00:00Z", "end_time": "2024-03-01T10:00:02Z", "duration_ms": 2000.0,
+			},
+			{"ts": "2024-03-01T10:00:01Z", "msg": "started", "job_id": 2.0, "unmatched": "running", "age_ms": 1000.0},
+		})
+}
+
+func TestExplainPlan(t *testing.T) {
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{"-f", "field:value2", "--explain-plan", "not-existing-file"})
+	outBuffer := bytes.Buffer{}
+	cmd.SetOut(&outBuffer)
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, outBuffer.String(), ". kql\n")
+	assert.Contains(t, outBuffer.String(), ". jq (noop)\n")
+	assert.Contains(t, outBuffer.String(), ". pair (noop)\n")
+	assert.Contains(t, outBuffer.String(), ". collapse (noop)\n")
+	assert.Contains(t, outBuffer.String(), ". sample (noop)\n")
+}
+
+func TestShowRemoved(t *testing.T) {
+	testCmd(t,
+		[]string{"--show-removed", "-f", "field:value* and num>1", "-e", "value3"},
+		[]steps.JSON{{"field": "value1", "num": 1}, {"field": "value2", "num": 2}, {"field": "value3", "num": 3}},
+		[]steps.JSON{
+			{"field": "value1", "num": 1.0, "removed": `kql: does not match "num>1"`},
+			{"field": "value2", "num": 2.0},
+			{"field": "value3", "num": 3.0, "removed": `exclude: contains "value3"`},
+		})
+}
+
+func TestWhy(t *testing.T) {
+	testCmd(t,
+		[]string{"--why", "rnum=1", "-f", "field:value1"},
+		[]steps.JSON{{"field": "value1"}, {"field": "value2"}, {"field": "value3"}},
+		[]steps.JSON{{"field": "value2", "removed": `kql: does not match "field:value1"`}})
+
+	testCmd(t,
+		[]string{"--why", "rnum=0 file=stdin", "-f", "field:value1"},
+		[]steps.JSON{{"field": "value1"}, {"field": "value2"}},
+		[]steps.JSON{{"field": "value1"}})
+
+	testCmd(t,
+		[]string{"--why", "rnum=0", "-f", "field:value1 AND other:x"},
+		[]steps.JSON{{"field": "value1", "other": "y"}},
+		[]steps.JSON{{"field": "value1", "other": "y", "removed": `kql: does not match "other:x"`}})
+
+	input := []steps.JSON{{"field": "value1"}, {"field": "value2"}, {"field": "value3"}, {"field": "value4"}}
+	testCmd(t,
+		[]string{"--why", "rnum=2", "--first", "1"},
+		input,
+		[]steps.JSON{{"field": "value3", "removed": "first: after the first 1 records"}})
+
+	testCmd(t,
+		[]string{"--why", "rnum=1", "--last", "2"},
+		input,
+		[]steps.JSON{{"field": "value2", "removed": "last: before the last 2 records"}})
+
+	testCmd(t,
+		[]string{"--show-removed", "--last", "2", "-e", "value3"},
+		input,
+		[]steps.JSON{
+			{"field": "value1", "removed": "last: before the last 2 records"},
+			{"field": "value2"},
+			{"field": "value3", "removed": `exclude: contains "value3"`},
+			{"field": "value4"},
+		})
+}
+
+func TestRange(t *testing.T) {
+	input := []steps.JSON{{"a": 0}, {"a": 1}, {"a": 2}, {"a": 3}, {"a": 4}}
+
+	testCmd(t,
+		[]string{"--range", "1-2", "--range", "4-"},
+		input,
+		[]steps.JSON{{"a": 1.0}, {"a": 2.0}, {"a": 4.0}})
+
+	testCmd(t,
+		[]string{"--range", "stdin:3-"},
+		input,
+		[]steps.JSON{{"a": 3.0}, {"a": 4.0}})
+
+	fileName := filepath.Join(t.TempDir(), "b.log")
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"b": 0}`+"\n"+`{"b": 1}`+"\n"+`{"b": 2}`+"\n"), 0o600))
+	// the range file name is matched to the input file name after cleaning
+	testCmd(t,
+		[]string{"--range", filepath.Dir(fileName) + "/./b.log:1", fileName},
+		[]steps.JSON{},
+		[]steps.JSON{{"b": 1.0}})
+
+	params := parseFilterParams(t, []string{"--range", "other.log:3-"})
+	params.fileNames = []string{"-", "app.log"}
+	assert.EqualError(t, params.Validate(), `invalid range "other.log:3-": other.log is not an input file`)
+}
+
+func TestSample(t *testing.T) {
+	input := make([]steps.JSON, 0, 10)
+	for i := range 10 {
+		input = append(input, steps.JSON{"a": i, "trace": i % 2})
+	}
+
+	testCmd(t,
+		[]string{"--sample", "every=4 field=rate", "-f", "not a:0"},
+		input,
+		[]steps.JSON{
+			{"a": 1.0, "trace": 1.0, "rate": 0.25},
+			{"a": 5.0, "trace": 1.0, "rate": 0.25},
+			{"a": 9.0, "trace": 1.0, "rate": 0.25}})
+
+	testCmd(t,
+		[]string{"--sample", "100%"},
+		input[:2],
+		[]steps.JSON{{"a": 0.0, "trace": 0.0}, {"a": 1.0, "trace": 1.0}})
+
+	testCmd(t,
+		[]string{"--sample", "0.0000001 by=trace"},
+		input,
+		[]steps.JSON{})
+
+	for _, spec := range []string{"0", "0%", "every=0", "by=trace"} {
+		_, err := parseSample(pipeline.PipelineOptions{}, spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	input := []steps.JSON{
+		{"ts": "2024-01-01T00:00:01Z", "level": "error", "msg": "connection refused"},
+		{"ts": "2024-01-01T00:00:02Z", "level": "error", "msg": "connection refused"},
+		{"ts": "2024-01-01T00:00:03Z", "level": "info", "msg": "retrying"},
+		{"ts": "2024-01-01T00:00:04Z", "level": "error", "msg": "connection refused"},
+	}
+
+	testCmd(t,
+		[]string{"--collapse"},
+		input,
+		[]steps.JSON{
+			{"ts": "2024-01-01T00:00:01Z", "level": "error", "msg": "connection refused", "repeated": 2.0,
+				"first_seen": "2024-01-01T00:00:01Z", "last_seen": "2024-01-01T00:00:02Z"},
+			{"ts": "2024-01-01T00:00:03Z", "level": "info", "msg": "retrying"},
+			{"ts": "2024-01-01T00:00:04Z", "level": "error", "msg": "connection refused"}})
+
+	testCmd(t,
+		[]string{"--collapse-window", "10s", "--collapse-by", "msg"},
+		input,
+		[]steps.JSON{
+			{"ts": "2024-01-01T00:00:01Z", "level": "error", "msg": "connection refused", "repeated": 3.0,
+				"first_seen": "2024-01-01T00:00:01Z", "last_seen": "2024-01-01T00:00:04Z"},
+			{"ts": "2024-01-01T00:00:03Z", "level": "info", "msg": "retrying"}})
+}
+
+func TestPatterns(t *testing.T) {
+	input := []steps.JSON{
+		{"msg": "user alice logged in from 10.0.0.1"},
+		{"msg": "connection refused"},
+		{"msg": "user bob logged in from 10.0.0.2"},
+	}
+	userTemplate := "user <*> logged in from <IP>"
+
+	testCmd(t,
+		[]string{"--patterns"},
+		input,
+		[]steps.JSON{
+			{"id": patterns.TemplateID(userTemplate), "count": 2.0, "template": userTemplate,
+				"example": map[string]any{"msg": "user alice logged in from 10.0.0.1"}},
+			{"id": patterns.TemplateID("connection refused"), "count": 1.0, "template": "connection refused",
+				"example": map[string]any{"msg": "connection refused"}}})
+
+	logFile := filepath.Join(t.TempDir(), "app.log")
+	content, err := io.ReadAll(marshalJson(t, input))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(logFile, content, 0o600))
+
+	cmd := createRootCmd()
+	cmd.SetArgs([]string{logFile, "--pattern", patterns.TemplateID(userTemplate), "--format", "json", "--metadata", ""})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Execute())
+	checkOutput(t, []steps.JSON{input[0], input[2]}, &out)
+
+	cmd = createRootCmd()
+	out.Reset()
+	cmd.SetOut(&out)
+	cmd.SetIn(marshalJson(t, input))
+	cmd.SetArgs([]string{"-", "--patterns", "--metadata", ""})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t,
+		"2  "+patterns.TemplateID(userTemplate)+"  "+userTemplate+"\n"+
+			"             {\"msg\":\"user alice logged in from 10.0.0.1\"}\n"+
+			"1  "+patterns.TemplateID("connection refused")+"  connection refused\n"+
+			"             {\"msg\":\"connection refused\"}\n",
+		out.String())
+}
+
+func TestBaseline(t *testing.T) {
+	login := func(user string) steps.JSON {
+		return steps.JSON{"level": "info", "msg": "user " + user + " logged in"}
+	}
+	baseline := []steps.JSON{
+		login("a"), login("b"), login("c"), login("d"), login("e"),
+		{"level": "info", "msg": "cache warmed"},
+		{"level": "error", "msg": "disk full"},
+	}
+	baselineFile := filepath.Join(t.TempDir(), "baseline.log")
+	content, err := io.ReadAll(marshalJson(t, baseline))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(baselineFile, content, 0o600))
+
+	testCmd(t,
+		[]string{"--baseline", baselineFile},
+		[]steps.JSON{
+			login("f"), login("g"), login("h"), login("i"), login("j"),
+			{"level": "warn", "msg": "disk full"},
+			{"level": "error", "msg": "payment failed for order 42"},
+		},
+		[]steps.JSON{
+			{"status": "new", "id": patterns.TemplateID("payment failed for order <NUM>"), "template": "payment failed for order <NUM>",
+				"baseline_count": 0.0, "count": 1.0, "levels": map[string]any{"error": 1.0},
+				"example": map[string]any{"level": "error", "msg": "payment failed for order 42"}},
+			{"status": "changed", "id": patterns.TemplateID("disk full"), "template": "disk full",
+				"baseline_count": 1.0, "count": 1.0, "baseline_levels": map[string]any{"error": 1.0}, "levels": map[string]any{"warn": 1.0},
+				"example": map[string]any{"level": "warn", "msg": "disk full"}},
+			{"status": "disappeared", "id": patterns.TemplateID("cache warmed"), "template": "cache warmed",
+				"baseline_count": 1.0, "count": 0.0, "baseline_levels": map[string]any{"info": 1.0},
+				"example": map[string]any{"level": "info", "msg": "cache warmed"}},
+		})
+}
+
+func TestSummariesTogether(t *testing.T) {
+	params := parseFilterParams(t, []string{"--patterns", "--top", "user"})
+	assert.EqualError(t, params.Validate(), "--patterns, --top can not be used together")
+
+	params = parseFilterParams(t, []string{"--histogram", "1m", "--baseline", "old.log"})
+	assert.EqualError(t, params.Validate(), "--baseline, --histogram can not be used together")
+
+	params = parseFilterParams(t, []string{"--histogram", "1m"})
+	assert.NoError(t, params.Validate())
+
+	params = parseFilterParams(t, []string{"--baseline", "old.log", "--pattern", "abc"})
+	assert.EqualError(t, params.Validate(), "--pattern can not be used together with --baseline")
+}
+
+func TestContextBy(t *testing.T) {
+	testCmd(t,
+		[]string{"--context-by", "trace", "-f", "level:error"},
+		[]steps.JSON{
+			{"trace": "a", "level": "info", "msg": "start"},
+			{"trace": "b", "level": "info", "msg": "start"},
+			{"trace": "c", "level": "info", "msg": "start"},
+			{"trace": "b", "level": "error", "msg": "failed"},
+			{"trace": "a", "level": "info", "msg": "done"},
+			{"trace": "b", "level": "info", "msg": "retry"},
+		},
+		[]steps.JSON{
+			{"trace": "b", "level": "info", "msg": "start", "context": true},
+			{"trace": "b", "level": "error", "msg": "failed"},
+			{"trace": "b", "level": "info", "msg": "retry", "context": true},
+		})
+}
+
+func TestJqEnv(t *testing.T) {
+	input := []steps.JSON{
+		{"user": "john", "ts": "2024-03-01T10:00:01Z", "latency": "1.5s"},
+		{"user": "bob", "ts": "2024-03-01 10:00:02", "latency": "250ms"},
+	}
+
+	testCmd(t,
+		[]string{"--jq", ".user==$user", "--jq-arg", "user=bob"},
+		input,
+		[]steps.JSON{input[1]})
+
+	testCmd(t,
+		[]string{"--jq", "(.latency | toduration) > $limit.seconds", "--jq-argjson", `limit={"seconds":1}`},
+		input,
+		[]steps.JSON{input[0]})
+
+	testCmd(t,
+		[]string{"--jq", "{user, t: (.ts | totime)}"},
+		input,
+		[]steps.JSON{{"user": "john", "t": 1709287201.0}, {"user": "bob", "t": 1709287202.0}})
+
+	libDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(libDir, "auth.jq"), []byte(`def admin: .user == "john";`), 0o600))
+	testCmd(t,
+		[]string{"--jq", `import "auth" as auth; auth::admin`, "--jq-library-path", libDir},
+		input,
+		[]steps.JSON{input[0]})
+}
+
+func TestJqSlurp(t *testing.T) {
+	input := []steps.JSON{
+		{"endpoint": "/a", "duration": 10},
+		{"endpoint": "/b", "duration": 100},
+		{"endpoint": "/a", "duration": 30},
+		{"endpoint": "/c", "duration": 1},
+	}
+
+	testCmd(t,
+		[]string{
+			"-f", "not endpoint:\"/c\"",
+			"--jq-slurp", "group_by(.endpoint) | map({endpoint: .[0].endpoint, avg: (map(.duration) | add / length)}) | .[]"},
+		input,
+		[]steps.JSON{{"endpoint": "/a", "avg": 20.0}, {"endpoint": "/b", "avg": 100.0}})
+
+	testCmd(t,
+		[]string{"--jq-slurp", "length"},
+		input,
+		[]steps.JSON{{"item": 4.0}})
+}
+
+// parseFilterParams parses the flags of the root command without running it.
+func parseFilterParams(t *testing.T, args []string) *filterParams {
+	t.Helper()
+	var params filterParams
+	k := koanf.New(".")
+	cmd := &cobra.Command{}
+	defineFlags(config.NewRegistry(k, cmd.Flags()), &params)
+	require.NoError(t, cmd.ParseFlags(args))
+	require.NoError(t, loadConfiguration(&params, k, cmd))
+	return &params
+}
+
 func testCmd(t *testing.T, args []string, in []steps.JSON, expectedOut []steps.JSON) {
 	t.Helper()
 	cmd := createRootCmd()
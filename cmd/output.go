@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/vladimir-rom/logex/colors"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+var textFormatParams = []pipeline.ParamDef{
+	{Name: "txt-head", Type: pipeline.ParamStrings},
+	{Name: "order", Type: pipeline.ParamStrings},
+	{Name: "txt-nonl", Type: pipeline.ParamBool},
+	{Name: "txt-noprop", Type: pipeline.ParamBool},
+	{Name: "txt-delim", Type: pipeline.ParamString},
+	{Name: "highlight", Type: pipeline.ParamStrings},
+	{Name: "group-separator", Type: pipeline.ParamString},
+}
+
+type outputSpec struct {
+	format string
+	path   string
+	params pipeline.Params
+}
+
+func parseOutputSpec(spec string) (*outputSpec, error) {
+	fields, err := pipeline.SplitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty output specification")
+	}
+
+	format, path, found := strings.Cut(fields[0], ":")
+	if !found || len(path) == 0 {
+		return nil, fmt.Errorf("invalid output %q, expected format:path", spec)
+	}
+
+	var defs []pipeline.ParamDef
+	switch format {
+	case "text":
+		defs = textFormatParams
+	case "json":
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+
+	params, err := pipeline.ParseParams(defs, fields[1:])
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", fields[0], err)
+	}
+
+	return &outputSpec{
+		format: format,
+		path:   path,
+		params: params,
+	}, nil
+}
+
+func createSinks(
+	opts pipeline.PipelineOptions,
+	params *filterParams,
+	stdout io.Writer,
+	dryRun bool) ([]steps.Sink, func(), error) {
+	specs := make([]*outputSpec, 0, len(params.outputs()))
+	for _, o := range params.outputs() {
+		spec, err := parseOutputSpec(o)
+		if err != nil {
+			return nil, nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	var sinks []steps.Sink
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	if !slices.ContainsFunc(specs, func(s *outputSpec) bool { return s.path == "-" }) {
+		format := params.outputFormat()
+		if len(params.headProps()) > 0 {
+			format = "text"
+		}
+		specs = slices.Insert(specs, 0, &outputSpec{format: format, path: "-"})
+	}
+
+	for _, spec := range specs {
+		w := stdout
+		colorBuilder := colors.DefaultColorBuilder
+		if dryRun {
+			w = io.Discard
+		} else if spec.path != "-" {
+			f, err := os.Create(spec.path)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			bw := bufio.NewWriter(f)
+			closers = append(closers, func() error {
+				bw.Flush()
+				return f.Close()
+			})
+			w = bw
+			colorBuilder = colors.PlainColorBuilder
+		}
+
+		format, err := createFormatter(opts.Named("output "+spec.format+":"+spec.path), params, spec, colorBuilder)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		sinks = append(sinks, steps.Sink{
+			Writer:     w,
+			Format:     format,
+			ShowErrors: params.showErrors() && spec.path == "-",
+		})
+	}
+
+	return sinks, closeAll, nil
+}
+
+func createFormatter(
+	opts pipeline.PipelineOptions,
+	params *filterParams,
+	spec *outputSpec,
+	colorBuilder colors.ColorBuilder) (pipeline.Step[steps.JSON, string], error) {
+	if spec.format == "json" {
+		return steps.JsonToStr(opts), nil
+	}
+
+	p := pipeline.Params{
+		"txt-head":        params.headProps(),
+		"order":           params.orderProps(),
+		"txt-nonl":        params.textNoNewLine(),
+		"txt-noprop":      params.textNoProp(),
+		"txt-delim":       params.textDelim(),
+		"highlight":       slices.Concat(params.include(), params.highlights()),
+		"group-separator": params.groupSeparator(),
+	}
+	maps.Copy(p, spec.params)
+
+	return steps.JsonToText(
+		opts,
+		p.Strings("txt-head"),
+		p.Strings("order"),
+		p.Bool("txt-nonl"),
+		p.Bool("txt-noprop"),
+		p.String("txt-delim"),
+		p.Strings("highlight"),
+		p.String("group-separator"),
+		params.propertiesConfig,
+		colorBuilder)
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/patterns"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+func minePatterns(params *filterParams, records pipeline.Seq[steps.JSON]) []*patterns.Cluster {
+	miner := patterns.NewMiner(patterns.DefaultConfig)
+	path := steps.SplitPath(params.patternField())
+	for rec, err := range records {
+		if err != nil || rec.Metadata.Removed {
+			continue
+		}
+		if msg, ok := steps.MessageOf(rec.Value, path); ok {
+			miner.Add(msg, rec.Value)
+		}
+	}
+	return miner.Clusters()
+}
+
+// applyPatternIDs resolves the templates of the --pattern ids for the pattern filter.
+func applyPatternIDs(params *filterParams, cmd *cobra.Command) error {
+	if len(params.patternIDs()) == 0 {
+		return nil
+	}
+	templates, err := resolvePatterns(params, cmd)
+	if err != nil {
+		return err
+	}
+	params.patternTemplates = templates
+	return nil
+}
+
+// resolvePatterns mines the patterns from the input files and returns the templates with the specified ids.
+func resolvePatterns(params *filterParams, cmd *cobra.Command) ([]*patterns.Cluster, error) {
+	if slices.Contains(params.fileNames, "-") {
+		return nil, fmt.Errorf("--pattern can not be used with stdin, the input is read twice")
+	}
+
+	input, closeInput, err := openInput(params.fileNames, cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	var clusters []*patterns.Cluster
+	mining := *params
+	mining.patternIDs = func() []string { return nil }
+	mining.stats = func() bool { return false }
+	mining.consume = func(records pipeline.Seq[steps.JSON]) error {
+		clusters = minePatterns(params, records)
+		return nil
+	}
+	if err := runPipeline(&mining, input, io.Discard, io.Discard); err != nil {
+		return nil, err
+	}
+
+	res := make([]*patterns.Cluster, 0, len(params.patternIDs()))
+	for _, id := range params.patternIDs() {
+		idx := slices.IndexFunc(clusters, func(c *patterns.Cluster) bool { return c.ID() == id })
+		if idx < 0 {
+			return nil, fmt.Errorf("pattern %s not found", id)
+		}
+		res = append(res, clusters[idx])
+	}
+	return res, nil
+}
+
+type patternOutput struct {
+	ID       string     `json:"id"`
+	Count    int        `json:"count"`
+	Template string     `json:"template"`
+	Example  steps.JSON `json:"example"`
+}
+
+func printPatterns(w io.Writer, format string, clusters []*patterns.Cluster) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for _, c := range clusters {
+			err := enc.Encode(patternOutput{
+				ID:       c.ID(),
+				Count:    c.Count,
+				Template: c.Template(),
+				Example:  c.Example.(steps.JSON),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	countWidth := 1
+	if len(clusters) > 0 {
+		countWidth = len(strconv.Itoa(clusters[0].Count))
+	}
+	for _, c := range clusters {
+		example, err := json.Marshal(c.Example)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%*d  %s  %s\n%*s  %s\n", countWidth, c.Count, c.ID(), c.Template(), countWidth+8+2, "", example)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func printPlan(w io.Writer, plan *pipeline.Plan) error {
+	i := 0
+	for s := range plan.All {
+		i++
+		noop := ""
+		if s.Noop {
+			noop = " (noop)"
+		}
+		if _, err := fmt.Fprintf(w, "%2d. %s%s\n", i, s.Name, noop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printStats(w io.Writer, plan *pipeline.Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "step\tin\tout\tremoved\terrors\ttime\t")
+	for s := range plan.All {
+		if s.Noop {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%v\t\n",
+			s.Name,
+			s.In(),
+			s.Out(),
+			s.Removed(),
+			s.Errors(),
+			s.Duration().Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func createQueriesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "queries",
+		Short: "List named queries and KQL macros defined in the configuration file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			configName, _ := cmd.Flags().GetString("config")
+			if len(configName) == 0 {
+				configName = os.Getenv("LOGEX_CONFIG")
+			}
+
+			var params filterParams
+			k := koanf.New(".")
+			if len(configName) > 0 {
+				if err := k.Load(file.Provider(configName), yaml.Parser()); err != nil {
+					log.Fatalf("error loading config file: %v", err)
+				}
+			}
+			if err := loadQueries(k, &params); err != nil {
+				log.Fatal(err)
+			}
+
+			printQueries(cmd.OutOrStdout(), params.queriesConfig, params.macrosConfig)
+		},
+	}
+}
+
+func loadQueries(k *koanf.Koanf, params *filterParams) error {
+	if err := k.Unmarshal("queries", &params.queriesConfig); err != nil {
+		return fmt.Errorf("error loading queries: %w", err)
+	}
+
+	params.macrosConfig = make(config.Macros)
+	macros, _ := k.Get("macros").(map[string]any)
+	for name, def := range macros {
+		if kql, ok := def.(string); ok {
+			params.macrosConfig[name] = config.Macro{KQL: kql}
+			continue
+		}
+
+		var m config.Macro
+		if err := k.Unmarshal("macros."+name, &m); err != nil {
+			return fmt.Errorf("error loading macro %s: %w", name, err)
+		}
+		params.macrosConfig[name] = m
+	}
+
+	return nil
+}
+
+func applyQueries(params *filterParams) error {
+	for _, spec := range params.queries() {
+		fields, err := pipeline.SplitSpec(spec)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		query, ok := params.queriesConfig[name]
+		if !ok {
+			return fmt.Errorf("unknown query: %s", name)
+		}
+
+		args := make(map[string]string)
+		for _, f := range fields[1:] {
+			argName, argValue, found := strings.Cut(f, "=")
+			if !found {
+				return fmt.Errorf("query %s: invalid parameter %q, expected name=value", name, f)
+			}
+			args[argName] = argValue
+		}
+
+		q, err := query.Bind(args)
+		if err != nil {
+			return fmt.Errorf("query %s: %w", name, err)
+		}
+
+		if len(q.Jq) > 0 {
+			if len(params.jq()) > 0 {
+				return fmt.Errorf("query %s: jq expression is already specified", name)
+			}
+			params.jq = func() string { return q.Jq }
+		}
+
+		if len(q.KQL) > 0 {
+			kql := params.kqlFilter
+			params.kqlFilter = func() string {
+				if prev := kql(); len(prev) > 0 {
+					return "(" + prev + ") and (" + q.KQL + ")"
+				}
+				return q.KQL
+			}
+		}
+
+		concat := func(prev func() []string, values []string) func() []string {
+			return func() []string { return slices.Concat(prev(), values) }
+		}
+		params.include = concat(params.include, q.Include)
+		params.exclude = concat(params.exclude, q.Exclude)
+		params.includeRegexp = concat(params.includeRegexp, q.IncludeRegexp)
+		params.excludeRegexp = concat(params.excludeRegexp, q.ExcludeRegexp)
+		params.where = concat(params.where, q.Where)
+		params.whereNot = concat(params.whereNot, q.WhereNot)
+	}
+
+	return nil
+}
+
+func printQueries(w io.Writer, queries config.Queries, macros config.Macros) {
+	fmt.Fprintln(w, "Queries (use --query 'name [param=value ...]'):")
+	for _, name := range sortedKeys(queries) {
+		q := queries[name]
+		printDefinition(w, name, q.Description, q.Params)
+		printValue(w, "kql", q.KQL)
+		printValue(w, "jq", q.Jq)
+		printValue(w, "include", q.Include...)
+		printValue(w, "exclude", q.Exclude...)
+		printValue(w, "include-regexp", q.IncludeRegexp...)
+		printValue(w, "exclude-regexp", q.ExcludeRegexp...)
+		printValue(w, "where", q.Where...)
+		printValue(w, "where-not", q.WhereNot...)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "KQL macros (use @name or @name(arg, ...) inside KQL expressions):")
+	for _, name := range sortedKeys(macros) {
+		m := macros[name]
+		printDefinition(w, name, m.Description, m.Params)
+		printValue(w, "kql", m.KQL)
+	}
+}
+
+func printDefinition(w io.Writer, name, description string, params []string) {
+	if len(params) > 0 {
+		name += " (" + strings.Join(params, ", ") + ")"
+	}
+	fmt.Fprintf(w, "  %-30s %s\n", name, description)
+}
+
+func printValue(w io.Writer, name string, values ...string) {
+	for _, v := range values {
+		if len(v) > 0 {
+			fmt.Fprintf(w, "      %-16s %s\n", name+":", v)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
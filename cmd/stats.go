@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+type statsParams struct {
+	by      func() []string
+	aggs    func() []string
+	sortBy  func() string
+	reverse func() bool
+}
+
+func createStatsCmd() *cobra.Command {
+	var params filterParams
+	var stats statsParams
+	var k = koanf.New(".")
+
+	statsCmd := &cobra.Command{
+		Use:   "stats [flags] file-name",
+		Short: "Group filtered records and compute count, distinct count, sum, avg, min, max and percentiles",
+		Example: "  logex stats --by service,ts/1m -f 'level:error' app.log\n" +
+			"  logex stats --by endpoint --agg 'count,avg(duration),p95(duration)' --sort p95_duration --desc app.log",
+		Run: func(cmd *cobra.Command, args []string) {
+			params.fileNames = args
+			params.config, _ = cmd.Flags().GetString("config")
+			err := loadConfiguration(&params, k, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = doStats(&params, &stats, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	reg := config.NewRegistry(k, statsCmd.Flags())
+	defineFlags(reg, &params)
+	rejectFlags(statsCmd, summaryFlags...)
+
+	stats.by = reg.Strings(
+		"by",
+		nil,
+		"Properties to group records by. A time property can be grouped by intervals: 'ts/1m'")
+	stats.aggs = reg.Strings(
+		"agg",
+		[]string{"count"},
+		"Aggregates to compute: count, count(field), distinct(field), sum(field), avg(field), min(field), max(field)\n"+
+			"and percentiles like p50(field), p90(field), p99(field). Non-numeric values are skipped, use --as-number or --as-duration to convert them")
+	stats.sortBy = reg.String(
+		"sort",
+		"",
+		"Column to sort groups by, for example count or p99_duration (default is the group values)")
+	stats.reverse = reg.Bool(
+		"desc",
+		false,
+		"Sort groups in descending order")
+
+	return statsCmd
+}
+
+func doStats(params *filterParams, stats *statsParams, cmd *cobra.Command) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := applyPatternIDs(params, cmd); err != nil {
+		return err
+	}
+
+	by := make([]steps.GroupField, 0, len(stats.by()))
+	for _, spec := range stats.by() {
+		g, err := steps.ParseGroupField(spec)
+		if err != nil {
+			return err
+		}
+		by = append(by, g)
+	}
+	aggs := make([]steps.Aggregate, 0, len(stats.aggs()))
+	for _, spec := range stats.aggs() {
+		a, err := steps.ParseAggregate(spec)
+		if err != nil {
+			return err
+		}
+		aggs = append(aggs, a)
+	}
+
+	aggregator := steps.NewAggregator(by, aggs)
+	sortBy := stats.sortBy()
+	if len(sortBy) > 0 && !slices.Contains(aggregator.Columns(), sortBy) {
+		return fmt.Errorf("unknown sort column %q, expected one of %s", sortBy, strings.Join(aggregator.Columns(), ", "))
+	}
+
+	params.consume = func(records pipeline.Seq[steps.JSON]) error {
+		for rec, err := range records {
+			if err != nil {
+				params.reportError(cmd.ErrOrStderr(), err)
+				continue
+			}
+			if rec.Metadata.Removed {
+				continue
+			}
+			aggregator.Add(rec.Value)
+		}
+		return nil
+	}
+
+	input, closeInput, err := openInput(params.fileNames, cmd)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	if err := runPipeline(params, input, io.Discard, cmd.ErrOrStderr()); err != nil {
+		return err
+	}
+
+	results := aggregator.Results()
+	if len(sortBy) > 0 {
+		sortResults(results, sortBy, stats.reverse())
+	} else if stats.reverse() {
+		slices.Reverse(results)
+	}
+
+	if params.outputFormat() == "text" && len(params.headProps()) == 0 && len(params.outputs()) == 0 {
+		return printStatsTable(cmd.OutOrStdout(), aggregator.Columns(), results)
+	}
+
+	sinks, closeSinks, err := createSinks(pipeline.PipelineOptions{}, params, cmd.OutOrStdout(), false)
+	if err != nil {
+		return err
+	}
+	defer closeSinks()
+	return steps.WriteSinks(sinks, func(yield pipeline.Yield[steps.JSON]) {
+		for _, rec := range results {
+			if !yield(pipeline.Item[steps.JSON]{Value: rec}, nil) {
+				return
+			}
+		}
+	})
+}
+
+func sortResults(results []steps.JSON, column string, desc bool) {
+	slices.SortStableFunc(results, func(x, y steps.JSON) int {
+		if desc {
+			return steps.CompareValues(y[column], x[column])
+		}
+		return steps.CompareValues(x[column], y[column])
+	})
+}
+
+func printStatsTable(w io.Writer, columns []string, results []steps.JSON) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t")+"\t")
+	for _, rec := range results {
+		for _, c := range columns {
+			fmt.Fprint(tw, formatStatsValue(rec[c])+"\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func formatStatsValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case float64:
+		return strconv.FormatFloat(math.Round(val*1000)/1000, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+func createStepsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "steps",
+		Short: "List registered steps which can be added with --step",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			w := cmd.OutOrStdout()
+			fmt.Fprintln(w, "Line steps (applied to raw lines before JSON parsing):")
+			for def := range steps.StringSteps.All {
+				printStepDef(w, def.Name, def.Help, def.Params)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Record steps (applied to parsed JSON records):")
+			for def := range steps.JSONSteps.All {
+				help := def.Help
+				if def.Stateful {
+					help += " (applied after the files are merged)"
+				}
+				printStepDef(w, def.Name, help, def.Params)
+			}
+		},
+	}
+}
+
+func printStepDef(w io.Writer, name, help string, params []pipeline.ParamDef) {
+	fmt.Fprintf(w, "  %-20s %s\n", name, help)
+	for _, p := range params {
+		fmt.Fprintf(w, "      %-18s %s", p.Name+"="+p.Type.String(), p.Help)
+		if p.Default != nil {
+			fmt.Fprintf(w, " (default %v)", p.Default)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+func countTop(params *filterParams, records pipeline.Seq[steps.JSON]) []*steps.TopCounter {
+	counters := make([]*steps.TopCounter, 0, len(params.top()))
+	for _, field := range params.top() {
+		counters = append(counters, steps.NewTopCounter(field, params.topApprox()))
+	}
+
+	for rec, err := range records {
+		if err != nil || rec.Metadata.Removed {
+			continue
+		}
+		for _, c := range counters {
+			c.Add(rec.Value)
+		}
+	}
+	return counters
+}
+
+type topOutput struct {
+	Field   string  `json:"field"`
+	Value   any     `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+	Error   int     `json:"error,omitempty"`
+}
+
+func printTop(w io.Writer, format string, n int, counters []*steps.TopCounter) error {
+	percent := func(c *steps.TopCounter, count int) float64 {
+		if c.Records() == 0 {
+			return 0
+		}
+		return math.Round(float64(count)*1000/float64(c.Records())) / 10
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for _, c := range counters {
+			for _, v := range c.Top(n) {
+				err := enc.Encode(topOutput{
+					Field:   c.Field,
+					Value:   v.Value,
+					Count:   v.Count,
+					Percent: percent(c, v.Count),
+					Error:   v.Error,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for i, c := range counters {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		header := fmt.Sprintf("%s (%d records", c.Field, c.Records())
+		if c.Missing() > 0 {
+			header += fmt.Sprintf(", %d without the property", c.Missing())
+		}
+		if c.Approximate() {
+			header += ", approximate counts"
+		}
+		if _, err := fmt.Fprintln(w, header+")"); err != nil {
+			return err
+		}
+
+		top := c.Top(n)
+		values := make([]string, len(top))
+		counts := make([]string, len(top))
+		valueWidth, countWidth := 0, 0
+		for j, v := range top {
+			if s, ok := v.Value.(string); ok {
+				values[j] = s
+			} else {
+				value, err := json.Marshal(v.Value)
+				if err != nil {
+					return err
+				}
+				values[j] = string(value)
+			}
+			counts[j] = fmt.Sprint(v.Count)
+			if v.Error > 0 {
+				counts[j] = fmt.Sprintf("%d (±%d)", v.Count, v.Error)
+			}
+			valueWidth = max(valueWidth, utf8.RuneCountInString(values[j]))
+			countWidth = max(countWidth, utf8.RuneCountInString(counts[j]))
+		}
+		for j, v := range top {
+			_, err := fmt.Fprintf(w, "  %s%s  %s%s  %5.1f%%\n",
+				values[j], strings.Repeat(" ", valueWidth-utf8.RuneCountInString(values[j])),
+				strings.Repeat(" ", countWidth-utf8.RuneCountInString(counts[j])), counts[j],
+				percent(c, v.Count))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/spf13/cobra"
+	"github.com/vladimir-rom/logex/cmd/config"
+	"github.com/vladimir-rom/logex/colors"
+	"github.com/vladimir-rom/logex/pipeline"
+	"github.com/vladimir-rom/logex/steps"
+)
+
+// maxSpanNameWidth limits the width of the span name column including the tree lines
+const maxSpanNameWidth = 60
+
+type traceParams struct {
+	convention    func() string
+	traceField    func() string
+	spanField     func() string
+	parentField   func() string
+	nameFields    func() []string
+	startFields   func() []string
+	endField      func() string
+	durationField func() string
+	durationUnit  func() string
+	width         func() int
+	ascii         func() bool
+}
+
+func createTraceCmd() *cobra.Command {
+	var params filterParams
+	var trace traceParams
+	var k = koanf.New(".")
+
+	traceCmd := &cobra.Command{
+		Use:   "trace [flags] file-name...",
+		Short: "Group records by trace id, build span trees and print them as waterfalls",
+		Example: "  logex trace gateway.log orders.log db.log\n" +
+			"  logex trace -f 'trace_id:4bf92f3577b34da6' *.log\n" +
+			"  logex trace --convention zipkin spans.json",
+		Run: func(cmd *cobra.Command, args []string) {
+			params.fileNames = args
+			params.config, _ = cmd.Flags().GetString("config")
+			err := loadConfiguration(&params, k, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = doTrace(&params, &trace, cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	reg := config.NewRegistry(k, traceCmd.Flags())
+	defineFlags(reg, &params)
+	rejectFlags(traceCmd, append(summaryFlags, recordOutputFlags...)...)
+
+	trace.convention = reg.String(
+		"convention",
+		"",
+		"Span property names of a tracing format: otel (traceId, spanId, parentSpanId, startTimeUnixNano, endTimeUnixNano)\n"+
+			"or zipkin (traceId, id, parentId, timestamp, duration in microseconds).\n"+
+			"By default: trace_id, span_id, parent_span_id, start time in --time-fields and duration")
+	trace.traceField = reg.String(
+		"trace-field",
+		"",
+		"Trace id property, overrides the convention")
+	trace.spanField = reg.String(
+		"span-field",
+		"",
+		"Span id property, overrides the convention")
+	trace.parentField = reg.String(
+		"parent-field",
+		"",
+		"Parent span id property, overrides the convention")
+	trace.nameFields = reg.Strings(
+		"name-field",
+		nil,
+		"Span name properties, the first found is used (default name, span_name, operation and --pattern-field)")
+	trace.startFields = reg.Strings(
+		"start-field",
+		nil,
+		"Span start time properties, overrides the convention")
+	trace.endField = reg.String(
+		"end-field",
+		"",
+		"Span end time property used if there is no duration, overrides the convention")
+	trace.durationField = reg.String(
+		"duration-field",
+		"",
+		"Span duration property with a duration string like 15ms or a number of --duration-unit, overrides the convention")
+	trace.durationUnit = reg.String(
+		"duration-unit",
+		"",
+		"Unit of numeric durations: ns, us, ms, s (default ms, us for zipkin)")
+	trace.width = reg.Int(
+		"width",
+		40,
+		"Width of the waterfall bars in characters")
+	trace.ascii = reg.Bool(
+		"ascii",
+		false,
+		"Draw the waterfall with ASCII characters")
+
+	return traceCmd
+}
+
+// fields returns the span property names of the convention with the overrides.
+func (t *traceParams) fields(params *filterParams) (steps.TraceFields, error) {
+	fields := steps.TraceFields{
+		TraceID:  "trace_id",
+		SpanID:   "span_id",
+		ParentID: "parent_span_id",
+		Name:     []string{"name", "span_name", "operation", params.patternField()},
+		Start:    params.timeFields(),
+		Duration: "duration",
+	}
+	if len(t.convention()) > 0 {
+		var ok bool
+		fields, ok = steps.TraceConventions[t.convention()]
+		if !ok {
+			return fields, fmt.Errorf("unknown trace convention %q, expected otel or zipkin", t.convention())
+		}
+	}
+
+	override := func(field *string, value string) {
+		if len(value) > 0 {
+			*field = value
+		}
+	}
+	override(&fields.TraceID, t.traceField())
+	override(&fields.SpanID, t.spanField())
+	override(&fields.ParentID, t.parentField())
+	override(&fields.End, t.endField())
+	override(&fields.Duration, t.durationField())
+	if len(t.nameFields()) > 0 {
+		fields.Name = t.nameFields()
+	}
+	if len(t.startFields()) > 0 {
+		fields.Start = t.startFields()
+	}
+	if len(t.durationUnit()) > 0 {
+		unit, err := time.ParseDuration("1" + t.durationUnit())
+		if err != nil {
+			return fields, fmt.Errorf("invalid --duration-unit %q", t.durationUnit())
+		}
+		fields.DurationUnit = unit
+	}
+	return fields, nil
+}
+
+func doTrace(params *filterParams, trace *traceParams, cmd *cobra.Command) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if err := applyPatternIDs(params, cmd); err != nil {
+		return err
+	}
+	fields, err := trace.fields(params)
+	if err != nil {
+		return err
+	}
+
+	builder := steps.NewTraceBuilder(fields)
+	params.consume = func(records pipeline.Seq[steps.JSON]) error {
+		for rec, err := range records {
+			if err != nil {
+				params.reportError(cmd.ErrOrStderr(), err)
+				continue
+			}
+			if rec.Metadata.Removed {
+				continue
+			}
+			builder.Add(rec.Value)
+		}
+		return nil
+	}
+
+	input, closeInput, err := openInput(params.fileNames, cmd)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	errW := cmd.ErrOrStderr()
+	if err := runPipeline(params, input, io.Discard, errW); err != nil {
+		return err
+	}
+
+	if builder.Skipped() > 0 {
+		fmt.Fprintf(errW, "warning: %d records without start time in %v are skipped\n", builder.Skipped(), fields.Start)
+	}
+	traces := builder.Traces()
+	if len(traces) == 0 {
+		fmt.Fprintln(errW, "warning:", "no records with the trace id property", fields.TraceID)
+		return nil
+	}
+
+	if params.outputFormat() == "json" {
+		return printTracesJSON(cmd.OutOrStdout(), traces)
+	}
+	c, err := colors.NewColorizer(params.propertiesConfig, colors.DefaultColorBuilder)
+	if err != nil {
+		return err
+	}
+	return printWaterfalls(cmd.OutOrStdout(), traces, trace, func(s *steps.Span) colors.StrColorizer {
+		return c.LevelColor(fmt.Sprint(s.Record[params.levelField()]))
+	})
+}
+
+type spanOutput struct {
+	TraceID    string   `json:"trace_id"`
+	SpanID     string   `json:"span_id,omitempty"`
+	ParentID   string   `json:"parent_id,omitempty"`
+	Depth      int      `json:"depth"`
+	Name       string   `json:"name,omitempty"`
+	Start      string   `json:"start"`
+	OffsetMs   float64  `json:"offset_ms"`
+	DurationMs *float64 `json:"duration_ms"`
+	Orphan     bool     `json:"orphan,omitempty"`
+}
+
+func printTracesJSON(w io.Writer, traces []*steps.Trace) error {
+	enc := json.NewEncoder(w)
+	for _, t := range traces {
+		var err error
+		t.Walk(func(s *steps.Span, depth int) bool {
+			out := spanOutput{
+				TraceID:  t.ID,
+				SpanID:   s.ID,
+				ParentID: s.ParentID,
+				Depth:    depth,
+				Name:     s.Name,
+				Start:    s.Start.Format(time.RFC3339Nano),
+				OffsetMs: durationMs(s.Start.Sub(t.Start)),
+				Orphan:   s.Orphan,
+			}
+			if s.HasDuration {
+				d := durationMs(s.Duration)
+				out.DurationMs = &d
+			}
+			err = enc.Encode(out)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// formatSpanDuration rounds the duration to three significant digits.
+func formatSpanDuration(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	digits := int(math.Floor(math.Log10(float64(d))))
+	return d.Round(time.Duration(math.Pow10(max(digits-2, 0)))).String()
+}
+
+type waterfallLine struct {
+	name     string
+	offset   string
+	duration string
+	span     *steps.Span
+}
+
+func printWaterfalls(w io.Writer, traces []*steps.Trace, trace *traceParams, color func(*steps.Span) colors.StrColorizer) error {
+	full, point := "█", "┆"
+	branch, lastBranch, vertical := "├─ ", "└─ ", "│  "
+	if trace.ascii() {
+		full, point = "#", "|"
+		branch, lastBranch, vertical = "|- ", "`- ", "|  "
+	}
+	width := max(trace.width(), 1)
+
+	for i, t := range traces {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		total := t.End.Sub(t.Start)
+		spans := fmt.Sprintf("%d spans", t.Spans)
+		if t.Spans == 1 {
+			spans = "1 span"
+		}
+		_, err := fmt.Fprintf(w, "trace %s  %s  %s  %s\n", t.ID, t.Start.Format(time.RFC3339Nano), spans, formatSpanDuration(total))
+		if err != nil {
+			return err
+		}
+
+		var lines []waterfallLine
+		var addLines func(spans []*steps.Span, indent string, depth int)
+		addLines = func(spans []*steps.Span, indent string, depth int) {
+			for i, s := range spans {
+				prefix, childIndent := "", ""
+				if depth > 0 {
+					prefix, childIndent = branch, vertical
+					if i == len(spans)-1 {
+						prefix, childIndent = lastBranch, "   "
+					}
+				}
+				line := waterfallLine{
+					name:     truncateRunes(indent+prefix+spanTitle(s), maxSpanNameWidth),
+					offset:   formatSpanDuration(s.Start.Sub(t.Start)),
+					duration: "?",
+					span:     s,
+				}
+				if s.HasDuration {
+					line.duration = formatSpanDuration(s.Duration)
+				}
+				lines = append(lines, line)
+				addLines(s.Children, indent+childIndent, depth+1)
+			}
+		}
+		addLines(t.Roots, "", 0)
+
+		nameWidth, offsetWidth, durationWidth := 0, 0, 0
+		for _, l := range lines {
+			nameWidth = max(nameWidth, utf8.RuneCountInString(l.name))
+			offsetWidth = max(offsetWidth, len(l.offset))
+			durationWidth = max(durationWidth, len(l.duration))
+		}
+
+		for _, l := range lines {
+			offset := l.span.Start.Sub(t.Start)
+			startCell, endCell := 0, width
+			if total > 0 {
+				startCell = int(float64(offset) * float64(width) / float64(total))
+				endCell = int(math.Round(float64(offset+l.span.Duration) * float64(width) / float64(total)))
+			}
+			startCell = min(startCell, width-1)
+			bar := point
+			if l.span.HasDuration {
+				bar = color(l.span)(strings.Repeat(full, max(endCell-startCell, 1)))
+			}
+
+			_, err := fmt.Fprintf(w, "%s%s  %*s  %*s  %s%s\n",
+				l.name, strings.Repeat(" ", nameWidth-utf8.RuneCountInString(l.name)),
+				offsetWidth, l.offset,
+				durationWidth, l.duration,
+				strings.Repeat(" ", startCell), bar)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func spanTitle(s *steps.Span) string {
+	title := s.Name
+	if len(title) == 0 {
+		title = s.ID
+	}
+	if len(title) == 0 {
+		title = "-"
+	}
+	if s.Orphan {
+		title += fmt.Sprintf(" (orphan, parent %s)", s.ParentID)
+	}
+	return title
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
@@ -15,6 +15,7 @@ type Colorizer struct {
 	cfg            config.Properties
 	colorBuilder   ColorBuilder
 	propColorizers map[string]StrColorizer
+	valueColors    map[string]func(val string) StrColorizer
 }
 
 type ColorBuilder func(value ...color.Attribute) StrColorizer
@@ -29,10 +30,12 @@ type defaultColors struct {
 	PropertyName StrColorizer
 	Highlight    StrColorizer
 	Timestamp    StrColorizer
+	Removed      StrColorizer
+	Context      StrColorizer
 }
 
 func newDefaultColors(cb ColorBuilder) *defaultColors {
-	gray := toStrColorizer(color.New(90).SprintFunc())
+	gray := cb(color.FgHiBlack)
 	return &defaultColors{
 		Err:          cb(color.FgRed),
 		Warn:         cb(color.FgYellow),
@@ -41,6 +44,8 @@ func newDefaultColors(cb ColorBuilder) *defaultColors {
 		PropertyName: gray,
 		Highlight:    cb(color.FgCyan),
 		Timestamp:    gray,
+		Removed:      cb(color.Faint),
+		Context:      cb(color.Faint),
 	}
 }
 
@@ -64,6 +69,12 @@ func DefaultColorBuilder(value ...color.Attribute) StrColorizer {
 	return toStrColorizer(c.SprintFunc())
 }
 
+func PlainColorBuilder(value ...color.Attribute) StrColorizer {
+	return func(s string) string {
+		return s
+	}
+}
+
 func toStrColorizer(cf func(a ...any) string) StrColorizer {
 	return func(s string) string {
 		return cf(s)
@@ -79,6 +90,7 @@ func (c *Colorizer) ForProperty(property string) StrColorizer {
 
 func (c *Colorizer) initPropertyColorizers() error {
 	c.propColorizers = make(map[string]StrColorizer)
+	c.valueColors = make(map[string]func(val string) StrColorizer)
 	for prop, conf := range c.cfg {
 		if len(conf.Colors) == 0 {
 			c.propColorizers[prop] = c.defaultColorizer(prop)
@@ -90,6 +102,7 @@ func (c *Colorizer) initPropertyColorizers() error {
 			if propColorizer == nil {
 				continue
 			}
+			c.valueColors[prop] = propColorizer
 
 			c.propColorizers[prop] = func(propValue string) string {
 				strCol := propColorizer(propValue)
@@ -197,17 +210,32 @@ func (c *Colorizer) properyColorizer(colConfigs config.Colors) (func(val string)
 	}, nil
 }
 
-func (c *Colorizer) colorizeLogLevel(loglevel string) string {
+// ValueColor returns the color configured for the property value.
+func (c *Colorizer) ValueColor(property, value string) (StrColorizer, bool) {
+	if f, ok := c.valueColors[property]; ok {
+		if col := f(value); col != nil {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+// LevelColor returns the color of the log level: error, warning, info or debug color.
+func (c *Colorizer) LevelColor(loglevel string) StrColorizer {
 	switch loglevel {
 	case "err", "error":
-		return c.Err(loglevel)
+		return c.Err
 	case "warn", "warning":
-		return c.Warn(loglevel)
+		return c.Warn
 	case "info":
-		return c.Info(loglevel)
+		return c.Info
 	case "deb", "debug":
-		return c.Debug(loglevel)
+		return c.Debug
 	default:
-		return c.def(loglevel)
+		return c.def
 	}
 }
+
+func (c *Colorizer) colorizeLogLevel(loglevel string) string {
+	return c.LevelColor(loglevel)(loglevel)
+}
@@ -48,6 +48,27 @@ func TestColorizer(t *testing.T) {
 	checkPropertyColor(t, testee, "unknown property", "unknown value")
 }
 
+func TestValueColor(t *testing.T) {
+	testee, err := NewColorizer(
+		config.Properties{
+			"level": config.Property{
+				Colors: []config.Color{{Color: config.PColorMagenta, Value: "fatal"}},
+			},
+		},
+		colorBuilder,
+	)
+	require.NoError(t, err)
+
+	col, ok := testee.ValueColor("level", "fatal")
+	require.True(t, ok)
+	require.Equal(t, fmt.Sprintf("%v", []color.Attribute{color.FgMagenta}), col("#"))
+
+	_, ok = testee.ValueColor("level", "error")
+	require.False(t, ok)
+	require.Equal(t, fmt.Sprintf("%v", []color.Attribute{color.FgRed}), testee.LevelColor("error")("#"))
+	require.Equal(t, "#", testee.LevelColor("trace")("#"))
+}
+
 func checkPropertyColor(t *testing.T, testee *Colorizer, propName, propValue string, expectedColor ...color.Attribute) {
 	t.Helper()
 	res := testee.ForProperty(propName)(propValue)
@@ -0,0 +1,228 @@
+// Package patterns groups log messages into templates with variable tokens masked
+// using a variant of the Drain algorithm (He et al., "Drain: An Online Log Parsing Approach with Fixed Depth Tree").
+package patterns
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Wildcard replaces tokens that differ between messages of the same template.
+const Wildcard = "<*>"
+
+type Config struct {
+	// Depth is the depth of the prefix tree, Depth-2 first tokens are used to find candidate templates
+	Depth int
+	// Similarity is the minimal fraction of equal tokens for a message to match a template
+	Similarity float64
+	// MaxChildren limits the number of children of a tree node, other tokens go to the wildcard child
+	MaxChildren int
+}
+
+var DefaultConfig = Config{
+	Depth:       3,
+	Similarity:  0.4,
+	MaxChildren: 100,
+}
+
+// Cluster is a group of messages sharing the same template.
+type Cluster struct {
+	Tokens []string
+	Count  int
+	// Example is the first record added to the cluster
+	Example any
+}
+
+// Template returns the template text.
+func (c *Cluster) Template() string {
+	return strings.Join(c.Tokens, " ")
+}
+
+// ID returns a short identifier of the template. Equal templates have equal identifiers.
+func (c *Cluster) ID() string {
+	return TemplateID(c.Template())
+}
+
+// Matches checks that the message fits the template.
+func (c *Cluster) Matches(message string) bool {
+	return c.MatchesTokens(Tokenize(message))
+}
+
+// MatchesTokens checks that the tokenized message fits the template.
+func (c *Cluster) MatchesTokens(tokens []string) bool {
+	if len(c.Tokens) != len(tokens) {
+		return false
+	}
+	for i, t := range c.Tokens {
+		if t != Wildcard && t != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TemplateID(template string) string {
+	h := fnv.New32a()
+	h.Write([]byte(template))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+type node struct {
+	children map[string]*node
+	clusters []*Cluster
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// Miner builds templates from the added messages.
+type Miner struct {
+	cfg      Config
+	byLength map[int]*node
+	clusters []*Cluster
+}
+
+func NewMiner(cfg Config) *Miner {
+	return &Miner{
+		cfg:      cfg,
+		byLength: make(map[int]*node),
+	}
+}
+
+// Add adds a message to the best matching cluster or creates a new one and returns the cluster.
+func (m *Miner) Add(message string, record any) *Cluster {
+	tokens := Tokenize(message)
+
+	leaf := m.leaf(tokens)
+	if c := m.bestCluster(leaf.clusters, tokens); c != nil {
+		for i, t := range tokens {
+			if c.Tokens[i] != t {
+				c.Tokens[i] = Wildcard
+			}
+		}
+		c.Count++
+		return c
+	}
+
+	c := &Cluster{Tokens: tokens, Count: 1, Example: record}
+	leaf.clusters = append(leaf.clusters, c)
+	m.clusters = append(m.clusters, c)
+	return c
+}
+
+// Clusters returns the clusters sorted by the number of messages in descending order.
+func (m *Miner) Clusters() []*Cluster {
+	res := slices.Clone(m.clusters)
+	slices.SortStableFunc(res, func(a, b *Cluster) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return res
+}
+
+func (m *Miner) leaf(tokens []string) *node {
+	n, ok := m.byLength[len(tokens)]
+	if !ok {
+		n = newNode()
+		m.byLength[len(tokens)] = n
+	}
+
+	for i := 0; i < m.cfg.Depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if isVariable(key) {
+			key = Wildcard
+		}
+		child, ok := n.children[key]
+		if !ok {
+			if len(n.children) >= m.cfg.MaxChildren {
+				key = Wildcard
+			}
+			if child, ok = n.children[key]; !ok {
+				child = newNode()
+				n.children[key] = child
+			}
+		}
+		n = child
+	}
+	return n
+}
+
+func (m *Miner) bestCluster(clusters []*Cluster, tokens []string) *Cluster {
+	var best *Cluster
+	bestSim, bestParams := -1.0, -1
+	for _, c := range clusters {
+		sim, params := similarity(c.Tokens, tokens)
+		if sim > bestSim || sim == bestSim && params > bestParams {
+			best, bestSim, bestParams = c, sim, params
+		}
+	}
+	if bestSim < m.cfg.Similarity {
+		return nil
+	}
+	return best
+}
+
+func similarity(template, tokens []string) (float64, int) {
+	if len(template) == 0 {
+		return 1, 0
+	}
+	equal, params := 0, 0
+	for i, t := range template {
+		if t == Wildcard {
+			params++
+		} else if t == tokens[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(template)), params
+}
+
+func isVariable(token string) bool {
+	return token == Wildcard || strings.IndexFunc(token, unicode.IsDigit) >= 0 ||
+		strings.HasPrefix(token, "<") && strings.HasSuffix(token, ">")
+}
+
+type mask struct {
+	regexp *regexp.Regexp
+	name   string
+	// check filters matched strings when set
+	check func(s string) bool
+}
+
+var masks = []mask{
+	{regexp: regexp.MustCompile(`"[^"]*"|'[^']*'`), name: "<STR>"},
+	{regexp: regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), name: "<UUID>"},
+	{regexp: regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), name: "<IP>"},
+	{
+		regexp: regexp.MustCompile(`\b(?:0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`),
+		name:   "<HEX>",
+		check: func(s string) bool {
+			return strings.HasPrefix(s, "0x") ||
+				strings.IndexFunc(s, unicode.IsDigit) >= 0 && strings.IndexFunc(s, unicode.IsLetter) >= 0
+		},
+	},
+	{regexp: regexp.MustCompile(`[-+]?\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?(?:ns|us|µs|ms|s|m|h|%|[kKMGT]i?B|B)?\b`), name: "<NUM>"},
+}
+
+// Tokenize masks variable parts of the message (quoted strings, ids, IP addresses and numbers)
+// and splits it into tokens.
+func Tokenize(message string) []string {
+	for _, m := range masks {
+		if m.check == nil {
+			message = m.regexp.ReplaceAllLiteralString(message, m.name)
+			continue
+		}
+		message = m.regexp.ReplaceAllStringFunc(message, func(s string) string {
+			if m.check(s) {
+				return m.name
+			}
+			return s
+		})
+	}
+	return strings.Fields(message)
+}
@@ -0,0 +1,48 @@
+package patterns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t,
+		[]string{"connection", "to", "<IP>", "refused", "after", "<NUM>"},
+		Tokenize("connection to 10.0.0.1:5432 refused after 250ms"))
+	assert.Equal(t,
+		[]string{"user", "<STR>", "request", "<UUID>", "object", "<HEX>", "<HEX>"},
+		Tokenize(`user "john doe" request 123e4567-e89b-12d3-a456-426614174000 object 5f3c9a2b71 0x1F`))
+	assert.Equal(t,
+		[]string{"accepted", "deadbeef", "size=<NUM>,", "ratio", "<NUM>"},
+		Tokenize("accepted deadbeef size=12KiB, ratio -0.5"))
+}
+
+func TestMiner(t *testing.T) {
+	m := NewMiner(DefaultConfig)
+	m.Add("user alice logged in from 10.0.0.1", 1)
+	m.Add("user bob logged in from 10.0.0.2", 2)
+	m.Add("connection refused", 3)
+	m.Add("user carol logged in from 10.0.0.3", 4)
+	m.Add("connection refused", 5)
+	m.Add("cache miss for key 42", 6)
+
+	clusters := m.Clusters()
+	require.Len(t, clusters, 3)
+
+	assert.Equal(t, "user <*> logged in from <IP>", clusters[0].Template())
+	assert.Equal(t, 3, clusters[0].Count)
+	assert.Equal(t, 1, clusters[0].Example)
+
+	assert.Equal(t, "connection refused", clusters[1].Template())
+	assert.Equal(t, 2, clusters[1].Count)
+	assert.Equal(t, 3, clusters[1].Example)
+
+	assert.Equal(t, "cache miss for key <NUM>", clusters[2].Template())
+
+	assert.True(t, clusters[0].Matches("user dave logged in from 192.168.1.1"))
+	assert.False(t, clusters[0].Matches("user dave logged out from 192.168.1.1"))
+	assert.Equal(t, TemplateID("user <*> logged in from <IP>"), clusters[0].ID())
+	assert.Len(t, clusters[0].ID(), 8)
+}
@@ -1,15 +1,33 @@
 package pipeline
 
-import "fmt"
-
 type PipelineOptions struct {
 	ContextEnabled bool
+	// KeepRemoved passes removed records through the pipeline and records the removal reason
+	KeepRemoved bool
+	// Plan collects steps statistics when set
+	Plan *Plan
+	// Warn reports warnings of steps, the warnings are dropped when it is nil
+	Warn func(msg string)
+
+	name  string
+	stats *StepStats
 }
 
 type Metadata struct {
 	Removed  bool
 	RecNum   int
 	FileName string
+	// RemovedBy contains the step name and the reason of the removal, filled only when KeepRemoved is set
+	RemovedBy string
+	// GroupStart marks the first record of a context group not adjacent to the previous group
+	GroupStart bool
+}
+
+func (m *Metadata) Remove(opts PipelineOptions, reason func() string) {
+	m.Removed = true
+	if opts.KeepRemoved {
+		m.RemovedBy = opts.name + ": " + reason()
+	}
 }
 
 type Item[Value any] struct {
@@ -39,10 +57,27 @@ func NewStep[In, Out any](opts PipelineOptions, sink func(item Item[In], yield Y
 	return NewStepWithFin(opts, sink, func(internalYield Yield[Out]) {})
 }
 
+// NewStepPerSeq creates a step with a new sink for each sequence it is applied to, so that the state of the sink
+// is not shared when the step processes several sequences concurrently.
+func NewStepPerSeq[In, Out any](opts PipelineOptions, newSink func() func(item Item[In], yield Yield[Out]) bool) Step[In, Out] {
+	if opts.stats != nil {
+		opts.stats.Noop = false
+	}
+	return func(in Seq[In]) Seq[Out] {
+		return NewStep(opts, newSink())(in)
+	}
+}
+
 func NewStepWithFin[In, Out any](
 	opts PipelineOptions,
 	sink func(item Item[In], yield Yield[Out]) bool,
 	finalize func(yield Yield[Out])) Step[In, Out] {
+	if opts.stats != nil {
+		opts.stats.Noop = false
+		sink = instrumentSink(opts.stats, sink)
+		finalize = instrumentFinalize(opts.stats, finalize)
+	}
+
 	return func(in Seq[In]) Seq[Out] {
 		return func(internalYield Yield[Out]) {
 			for v, err := range in {
@@ -52,7 +87,7 @@ func NewStepWithFin[In, Out any](
 						return
 					}
 				} else {
-					if opts.ContextEnabled || !v.Metadata.Removed {
+					if opts.ContextEnabled || opts.KeepRemoved || !v.Metadata.Removed {
 						if !sink(v, internalYield) {
 							return
 						}
@@ -65,9 +100,13 @@ func NewStepWithFin[In, Out any](
 }
 
 func Combine[Item any](steps ...Step[Item, Item]) Step[Item, Item] {
-	if len(steps) < 2 {
-		panic(fmt.Sprintf("cannt combine %d steps", len(steps)))
+	switch len(steps) {
+	case 0:
+		return func(in Seq[Item]) Seq[Item] { return in }
+	case 1:
+		return steps[0]
 	}
+
 	result := func(in Seq[Item]) Seq[Item] {
 		return steps[1](steps[0](in))
 	}
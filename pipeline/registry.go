@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ParamType int
+
+const (
+	ParamString ParamType = iota
+	ParamStrings
+	ParamInt
+	ParamBool
+)
+
+func (t ParamType) String() string {
+	switch t {
+	case ParamString:
+		return "string"
+	case ParamStrings:
+		return "strings"
+	case ParamInt:
+		return "int"
+	case ParamBool:
+		return "bool"
+	default:
+		return fmt.Sprintf("ParamType(%d)", int(t))
+	}
+}
+
+type ParamDef struct {
+	Name    string
+	Type    ParamType
+	Default any
+	Help    string
+}
+
+func (d ParamDef) Parse(value string) (any, error) {
+	switch d.Type {
+	case ParamString:
+		return value, nil
+	case ParamStrings:
+		if len(value) == 0 {
+			return []string(nil), nil
+		}
+		return strings.Split(value, ","), nil
+	case ParamInt:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: invalid int value %q", d.Name, value)
+		}
+		return i, nil
+	case ParamBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: invalid bool value %q", d.Name, value)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("parameter %s: unsupported type %v", d.Name, d.Type)
+	}
+}
+
+func (d ParamDef) defaultValue() any {
+	if d.Default != nil {
+		return d.Default
+	}
+	switch d.Type {
+	case ParamString:
+		return ""
+	case ParamStrings:
+		return []string(nil)
+	case ParamInt:
+		return 0
+	case ParamBool:
+		return false
+	default:
+		return nil
+	}
+}
+
+func (d ParamDef) check(value any) error {
+	ok := false
+	switch d.Type {
+	case ParamString:
+		_, ok = value.(string)
+	case ParamStrings:
+		_, ok = value.([]string)
+	case ParamInt:
+		_, ok = value.(int)
+	case ParamBool:
+		_, ok = value.(bool)
+	}
+	if !ok {
+		return fmt.Errorf("parameter %s: expected %v value, got %T", d.Name, d.Type, value)
+	}
+	return nil
+}
+
+type Params map[string]any
+
+func (p Params) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+func (p Params) Strings(name string) []string {
+	v, _ := p[name].([]string)
+	return v
+}
+
+func (p Params) Int(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+
+func (p Params) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+type StepFactory[In, Out any] func(opts PipelineOptions, params Params) (Step[In, Out], error)
+
+type StepDef[In, Out any] struct {
+	Name   string
+	Help   string
+	Params []ParamDef
+	// Stateful steps depend on the previous records, so they are applied once to the merged records of all files
+	Stateful bool
+	Factory  StepFactory[In, Out]
+}
+
+type Registry[In, Out any] struct {
+	defs map[string]StepDef[In, Out]
+}
+
+func NewRegistry[In, Out any]() *Registry[In, Out] {
+	return &Registry[In, Out]{
+		defs: make(map[string]StepDef[In, Out]),
+	}
+}
+
+func (r *Registry[In, Out]) Register(def StepDef[In, Out]) {
+	if len(def.Name) == 0 || def.Factory == nil {
+		panic("step name and factory are required")
+	}
+	if _, ok := r.defs[def.Name]; ok {
+		panic(fmt.Sprintf("step %s is already registered", def.Name))
+	}
+	r.defs[def.Name] = def
+}
+
+func (r *Registry[In, Out]) Lookup(name string) (StepDef[In, Out], bool) {
+	def, ok := r.defs[name]
+	return def, ok
+}
+
+func (r *Registry[In, Out]) All(yield func(def StepDef[In, Out]) bool) {
+	names := make([]string, 0, len(r.defs))
+	for name := range r.defs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if !yield(r.defs[name]) {
+			return
+		}
+	}
+}
+
+func (r *Registry[In, Out]) Create(opts PipelineOptions, name string, params Params) (Step[In, Out], error) {
+	def, ok := r.defs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown step: %s", name)
+	}
+
+	resolved := make(Params, len(def.Params))
+	for _, pd := range def.Params {
+		value, ok := params[pd.Name]
+		if !ok {
+			resolved[pd.Name] = pd.defaultValue()
+			continue
+		}
+		if err := pd.check(value); err != nil {
+			return nil, fmt.Errorf("step %s: %w", name, err)
+		}
+		resolved[pd.Name] = value
+	}
+
+	for p := range params {
+		if _, ok := resolved[p]; !ok {
+			return nil, fmt.Errorf("step %s: unknown parameter %s", name, p)
+		}
+	}
+
+	return def.Factory(opts.Named(name), resolved)
+}
+
+// Parse creates a step from its textual specification.
+// Format: name [param=value ...], list values are separated by commas,
+// values containing spaces can be quoted: filter='level:error or level:warn'.
+func (r *Registry[In, Out]) Parse(opts PipelineOptions, spec string) (Step[In, Out], error) {
+	fields, err := SplitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty step specification")
+	}
+
+	name := fields[0]
+	def, ok := r.defs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown step: %s", name)
+	}
+
+	params, err := ParseParams(def.Params, fields[1:])
+	if err != nil {
+		return nil, fmt.Errorf("step %s: %w", name, err)
+	}
+
+	return r.Create(opts, name, params)
+}
+
+// ParseParams parses name=value arguments according to the parameter definitions.
+func ParseParams(defs []ParamDef, args []string) (Params, error) {
+	params := make(Params)
+	for _, arg := range args {
+		pName, pValue, found := strings.Cut(arg, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid parameter %q, expected name=value", arg)
+		}
+
+		idx := slices.IndexFunc(defs, func(pd ParamDef) bool { return pd.Name == pName })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown parameter %s", pName)
+		}
+
+		value, err := defs[idx].Parse(pValue)
+		if err != nil {
+			return nil, err
+		}
+		params[pName] = value
+	}
+	return params, nil
+}
+
+func (r *Registry[In, Out]) Has(spec string) bool {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return false
+	}
+	_, ok := r.defs[fields[0]]
+	return ok
+}
+
+// IsStateful reports whether the step of the specification is registered as stateful.
+func (r *Registry[In, Out]) IsStateful(spec string) bool {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return false
+	}
+	return r.defs[fields[0]].Stateful
+}
+
+// SplitSpec splits a specification into space separated fields, quotes group fields with spaces.
+func SplitSpec(spec string) ([]string, error) {
+	var res []string
+	var cur strings.Builder
+	var quote rune
+	inField := false
+	for _, c := range spec {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				res = append(res, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(c)
+			inField = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in step specification: %s", spec)
+	}
+	if inField {
+		res = append(res, cur.String())
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Plan collects the steps of an assembled pipeline together with their statistics.
+type Plan struct {
+	mu    sync.Mutex
+	steps []*StepStats
+}
+
+type StepStats struct {
+	Name string
+	Noop bool
+
+	in       atomic.Int64
+	out      atomic.Int64
+	removed  atomic.Int64
+	errors   atomic.Int64
+	duration atomic.Int64
+}
+
+func NewPlan() *Plan {
+	return &Plan{}
+}
+
+func (p *Plan) add(name string) *StepStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := &StepStats{Name: name, Noop: true}
+	p.steps = append(p.steps, s)
+	return s
+}
+
+func (p *Plan) All(yield func(s *StepStats) bool) {
+	p.mu.Lock()
+	steps := p.steps
+	p.mu.Unlock()
+
+	for _, s := range steps {
+		if !yield(s) {
+			return
+		}
+	}
+}
+
+func (s *StepStats) In() int64               { return s.in.Load() }
+func (s *StepStats) Out() int64              { return s.out.Load() }
+func (s *StepStats) Removed() int64          { return s.removed.Load() }
+func (s *StepStats) Errors() int64           { return s.errors.Load() }
+func (s *StepStats) Duration() time.Duration { return time.Duration(s.duration.Load()) }
+
+// Named sets the step name and declares the step in the plan,
+// the step is considered as Noop until it is created by NewStep.
+func (o PipelineOptions) Named(name string) PipelineOptions {
+	o.name = name
+	if o.Plan != nil {
+		o.stats = o.Plan.add(name)
+	}
+	return o
+}
+
+func instrumentSink[In, Out any](
+	stats *StepStats,
+	sink func(item Item[In], yield Yield[Out]) bool) func(item Item[In], yield Yield[Out]) bool {
+	return func(item Item[In], yield Yield[Out]) bool {
+		if !item.Metadata.Removed {
+			stats.in.Add(1)
+		}
+
+		var downstream time.Duration
+		start := time.Now()
+		res := sink(item, instrumentYield(stats, item.Metadata.Removed, &downstream, yield))
+		stats.duration.Add(int64(time.Since(start) - downstream))
+		return res
+	}
+}
+
+func instrumentFinalize[Out any](stats *StepStats, finalize func(yield Yield[Out])) func(yield Yield[Out]) {
+	return func(yield Yield[Out]) {
+		var downstream time.Duration
+		start := time.Now()
+		finalize(instrumentYield(stats, false, &downstream, yield))
+		stats.duration.Add(int64(time.Since(start) - downstream))
+	}
+}
+
+func instrumentYield[Out any](stats *StepStats, inRemoved bool, downstream *time.Duration, yield Yield[Out]) Yield[Out] {
+	return func(item Item[Out], err error) bool {
+		switch {
+		case err != nil:
+			stats.errors.Add(1)
+		case item.Metadata.Removed:
+			if !inRemoved {
+				stats.removed.Add(1)
+			}
+		default:
+			stats.out.Add(1)
+		}
+
+		start := time.Now()
+		res := yield(item, err)
+		*downstream += time.Since(start)
+		return res
+	}
+}
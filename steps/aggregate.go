@@ -0,0 +1,318 @@
+package steps
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GroupField is a property records are grouped by.
+type GroupField struct {
+	Field string
+	// Interval groups time values into buckets of the interval, values are grouped as is when it is zero
+	Interval time.Duration
+	path     []string
+}
+
+// ParseGroupField parses a group field specification: 'field' or 'field/INTERVAL',
+// for example 'ts/1m' groups records by minutes.
+func ParseGroupField(spec string) (GroupField, error) {
+	field, interval, found := strings.Cut(spec, "/")
+	res := GroupField{Field: strings.TrimSpace(field), path: SplitPath(strings.TrimSpace(field))}
+	if len(res.Field) == 0 {
+		return res, fmt.Errorf("invalid group field %q", spec)
+	}
+	if found {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d <= 0 {
+			return res, fmt.Errorf("invalid interval of group field %q", spec)
+		}
+		res.Interval = d
+	}
+	return res, nil
+}
+
+// value returns the group value of the record, nil if the record has no such property.
+func (g GroupField) value(obj JSON) any {
+	var res any
+	ValuesByPath(obj, g.path, func(v any) bool {
+		res = v
+		return false
+	})
+	if g.Interval > 0 && res != nil {
+		t, ok := ParseTime(res)
+		if !ok {
+			return nil
+		}
+		return t.UTC().Truncate(g.Interval).Format(time.RFC3339)
+	}
+	return res
+}
+
+// Aggregate is a function computed over the records of a group.
+type Aggregate struct {
+	// Func is count, distinct, sum, avg, min, max or a percentile like p99
+	Func string
+	// Field is the property the function is computed over, count without a field counts all records
+	Field string
+	// Percentile is the percentile of the pNN functions
+	Percentile float64
+	path       []string
+}
+
+// ParseAggregate parses an aggregate specification: 'count', 'count(field)', 'distinct(field)', 'sum(field)',
+// 'avg(field)', 'min(field)', 'max(field)' or a percentile like 'p50(field)', 'p99.9(field)'.
+func ParseAggregate(spec string) (Aggregate, error) {
+	spec = strings.TrimSpace(spec)
+	fn, field, found := strings.Cut(spec, "(")
+	var res Aggregate
+	if found {
+		if !strings.HasSuffix(field, ")") {
+			return res, fmt.Errorf("invalid aggregate %q, expected 'func(field)'", spec)
+		}
+		field = strings.TrimSpace(strings.TrimSuffix(field, ")"))
+	}
+	res = Aggregate{Func: strings.TrimSpace(fn), Field: field, path: SplitPath(field)}
+
+	switch res.Func {
+	case "count":
+		return res, nil
+	case "distinct", "sum", "avg", "min", "max":
+	default:
+		p, err := strconv.ParseFloat(strings.TrimPrefix(res.Func, "p"), 64)
+		if !strings.HasPrefix(res.Func, "p") || err != nil || p <= 0 || p > 100 {
+			return res, fmt.Errorf("unknown aggregate function %q", res.Func)
+		}
+		res.Percentile = p
+	}
+	if len(res.Field) == 0 {
+		return res, fmt.Errorf("aggregate %s requires a field, for example %s(duration)", res.Func, res.Func)
+	}
+	return res, nil
+}
+
+// Name returns the name of the aggregate column, for example 'count' or 'avg_duration'.
+func (a Aggregate) Name() string {
+	if len(a.Field) == 0 {
+		return a.Func
+	}
+	return a.Func + "_" + a.Field
+}
+
+type aggregateState struct {
+	count    int
+	sum      float64
+	min      float64
+	max      float64
+	values   []float64
+	distinct map[string]struct{}
+}
+
+func (s *aggregateState) add(a Aggregate, obj JSON) {
+	if len(a.Field) == 0 {
+		s.count++
+		return
+	}
+
+	ValuesByPath(obj, a.path, func(v any) bool {
+		switch {
+		case a.Func == "count":
+			s.count++
+		case a.Func == "distinct":
+			if s.distinct == nil {
+				s.distinct = make(map[string]struct{})
+			}
+			s.distinct[fmt.Sprintf("%T:%v", v, v)] = struct{}{}
+		default:
+			f, ok := toFloat(v)
+			if !ok {
+				break
+			}
+			if s.count == 0 || f < s.min {
+				s.min = f
+			}
+			if s.count == 0 || f > s.max {
+				s.max = f
+			}
+			s.count++
+			s.sum += f
+			if a.Percentile > 0 {
+				s.values = append(s.values, f)
+			}
+		}
+		return false
+	})
+}
+
+func (s *aggregateState) result(a Aggregate) any {
+	switch a.Func {
+	case "count":
+		return s.count
+	case "distinct":
+		return len(s.distinct)
+	}
+
+	if s.count == 0 {
+		return nil
+	}
+	switch a.Func {
+	case "sum":
+		return s.sum
+	case "avg":
+		return s.sum / float64(s.count)
+	case "min":
+		return s.min
+	case "max":
+		return s.max
+	}
+	return percentile(s.values, a.Percentile)
+}
+
+// percentile returns the percentile of the values linearly interpolated between the closest ranks.
+func percentile(values []float64, p float64) float64 {
+	slices.Sort(values)
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(values) {
+		return values[len(values)-1]
+	}
+	return values[lower] + (values[lower+1]-values[lower])*(rank-float64(lower))
+}
+
+// toFloat converts numbers and numeric strings to float64.
+func toFloat(v any) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return number(v)
+}
+
+func number(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+type aggregateGroup struct {
+	keys   []any
+	states []aggregateState
+}
+
+// Aggregator groups records by the values of the group fields and computes the aggregates of the groups.
+type Aggregator struct {
+	by     []GroupField
+	aggs   []Aggregate
+	groups map[string]*aggregateGroup
+}
+
+func NewAggregator(by []GroupField, aggs []Aggregate) *Aggregator {
+	return &Aggregator{
+		by:     by,
+		aggs:   aggs,
+		groups: make(map[string]*aggregateGroup),
+	}
+}
+
+func (a *Aggregator) Add(obj JSON) {
+	keys := make([]any, len(a.by))
+	var sb strings.Builder
+	for i, g := range a.by {
+		keys[i] = g.value(obj)
+		fmt.Fprintf(&sb, "%T:%v\x00", keys[i], keys[i])
+	}
+
+	group, ok := a.groups[sb.String()]
+	if !ok {
+		group = &aggregateGroup{keys: keys, states: make([]aggregateState, len(a.aggs))}
+		a.groups[sb.String()] = group
+	}
+	for i, agg := range a.aggs {
+		group.states[i].add(agg, obj)
+	}
+}
+
+// Columns returns the names of the group fields followed by the names of the aggregates.
+func (a *Aggregator) Columns() []string {
+	res := make([]string, 0, len(a.by)+len(a.aggs))
+	for _, g := range a.by {
+		res = append(res, g.Field)
+	}
+	for _, agg := range a.aggs {
+		res = append(res, agg.Name())
+	}
+	return res
+}
+
+// Results returns a record per group sorted by the group values. Records contain the group values
+// and the aggregates by their column names, aggregates without numeric values are nil.
+func (a *Aggregator) Results() []JSON {
+	groups := make([]*aggregateGroup, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(x, y *aggregateGroup) int {
+		for i := range x.keys {
+			if c := CompareValues(x.keys[i], y.keys[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	res := make([]JSON, 0, len(groups))
+	for _, g := range groups {
+		rec := make(JSON, len(a.by)+len(a.aggs))
+		for i, f := range a.by {
+			rec[f.Field] = g.keys[i]
+		}
+		for i, agg := range a.aggs {
+			rec[agg.Name()] = g.states[i].result(agg)
+		}
+		res = append(res, rec)
+	}
+	return res
+}
+
+// CompareValues orders JSON values: nil first, then numbers by value, then other values by their text.
+func CompareValues(x, y any) int {
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return -1
+	case y == nil:
+		return 1
+	}
+
+	xf, xNum := number(x)
+	yf, yNum := number(y)
+	switch {
+	case xNum && yNum:
+		if xf < yf {
+			return -1
+		}
+		if xf > yf {
+			return 1
+		}
+		return 0
+	case xNum:
+		return -1
+	case yNum:
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(x), fmt.Sprint(y))
+}
@@ -0,0 +1,86 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAggregate(t *testing.T) {
+	a, err := ParseAggregate("count")
+	require.NoError(t, err)
+	assert.Equal(t, "count", a.Name())
+
+	a, err = ParseAggregate("p99.9(req.duration)")
+	require.NoError(t, err)
+	assert.Equal(t, 99.9, a.Percentile)
+	assert.Equal(t, "p99.9_req.duration", a.Name())
+
+	for _, invalid := range []string{"avg", "median(x)", "p0(x)", "p101(x)", "sum(x"} {
+		_, err := ParseAggregate(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseGroupField(t *testing.T) {
+	g, err := ParseGroupField("ts/5m")
+	require.NoError(t, err)
+	assert.Equal(t, "ts", g.Field)
+	assert.Equal(t, 5*time.Minute, g.Interval)
+
+	_, err = ParseGroupField("ts/often")
+	assert.Error(t, err)
+}
+
+func TestAggregator(t *testing.T) {
+	var by []GroupField
+	for _, spec := range []string{"service", "ts/1m"} {
+		g, err := ParseGroupField(spec)
+		require.NoError(t, err)
+		by = append(by, g)
+	}
+	var aggs []Aggregate
+	for _, spec := range []string{"count", "distinct(user)", "sum(duration)", "avg(duration)", "min(duration)", "max(duration)", "p50(duration)", "p90(duration)"} {
+		a, err := ParseAggregate(spec)
+		require.NoError(t, err)
+		aggs = append(aggs, a)
+	}
+
+	aggregator := NewAggregator(by, aggs)
+	for _, obj := range []JSON{
+		{"service": "db", "ts": "2024-03-01T10:01:30Z", "user": "a"},
+		{"service": "api", "ts": "2024-03-01T10:00:10Z", "user": "a", "duration": 10.0},
+		{"service": "api", "ts": "2024-03-01T10:00:20Z", "user": "b", "duration": "20"},
+		{"service": "api", "ts": float64(1709287250), "user": "a", "duration": 30.0},
+		{"service": "api", "ts": "2024-03-01T10:00:50Z", "duration": 40.0},
+		{"ts": "2024-03-01T10:00:00Z", "duration": "slow"},
+	} {
+		aggregator.Add(obj)
+	}
+
+	assert.Equal(t,
+		[]string{"service", "ts", "count", "distinct_user", "sum_duration", "avg_duration", "min_duration", "max_duration", "p50_duration", "p90_duration"},
+		aggregator.Columns())
+	res := aggregator.Results()
+	require.Len(t, res, 3)
+	assert.Equal(t, JSON{
+		"service": nil, "ts": "2024-03-01T10:00:00Z", "count": 1, "distinct_user": 0,
+		"sum_duration": nil, "avg_duration": nil, "min_duration": nil, "max_duration": nil, "p50_duration": nil, "p90_duration": nil,
+	}, res[0])
+	assert.Equal(t, JSON{
+		"service": "api", "ts": "2024-03-01T10:00:00Z", "count": 4, "distinct_user": 2,
+		"sum_duration": 100.0, "avg_duration": 25.0, "min_duration": 10.0, "max_duration": 40.0, "p50_duration": 25.0, "p90_duration": 37.0,
+	}, res[1])
+	assert.Equal(t, "db", res[2]["service"])
+	assert.Equal(t, "2024-03-01T10:01:00Z", res[2]["ts"])
+}
+
+func TestCompareValues(t *testing.T) {
+	assert.Equal(t, -1, CompareValues(nil, 1.0))
+	assert.Equal(t, 1, CompareValues(10.0, 9))
+	assert.Equal(t, -1, CompareValues(10.0, "1"))
+	assert.Equal(t, -1, CompareValues("a", "b"))
+	assert.Equal(t, 0, CompareValues(nil, nil))
+}
@@ -0,0 +1,28 @@
+package steps
+
+import (
+	"time"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// Around keeps records whose time is within the [from, to] interval.
+func Around(opts pipeline.PipelineOptions, timeProps []string, from, to time.Time) pipeline.Step[JSON, JSON] {
+	if from.IsZero() && to.IsZero() {
+		return Noop[JSON]()
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		t, ok := RecordTime(obj.Value, timeProps)
+		if !ok {
+			obj.Metadata.Remove(opts, func() string { return "no timestamp" })
+		} else if t.Before(from) || t.After(to) {
+			obj.Metadata.Remove(opts, func() string { return t.Format(time.RFC3339Nano) + " is out of the time window" })
+		}
+		return yield(obj, nil)
+	})
+}
@@ -0,0 +1,167 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// CollapseConfig describes collapsing of repeated records.
+type CollapseConfig struct {
+	// Fields are property paths identifying repeated records
+	Fields []string
+	// Window suppresses non-consecutive repeats within the time window after the first occurrence.
+	// Only consecutive repeats are collapsed when it is zero.
+	Window time.Duration
+	// TimeProps are property names of the record time
+	TimeProps []string
+}
+
+type collapseEntry struct {
+	item      pipeline.Item[JSON]
+	key       string
+	group     bool
+	count     int
+	firstSeen any
+	lastSeen  any
+	expires   time.Time
+}
+
+// Collapse replaces repeated records with the first one having the 'repeated' field
+// with the number of occurrences and 'first_seen' and 'last_seen' fields with their timestamps.
+// Records without any of the fields are not collapsed.
+func Collapse(opts pipeline.PipelineOptions, cfg CollapseConfig) pipeline.Step[JSON, JSON] {
+	if len(cfg.Fields) == 0 {
+		return Noop[JSON]()
+	}
+
+	paths := make([][]string, len(cfg.Fields))
+	for i, f := range cfg.Fields {
+		paths[i] = SplitPath(f)
+	}
+
+	var queue []*collapseEntry
+	active := make(map[string]*collapseEntry)
+
+	emit := func(e *collapseEntry, yield pipeline.Yield[JSON]) bool {
+		if e.group {
+			if active[e.key] == e {
+				delete(active, e.key)
+			}
+			if e.count > 1 {
+				e.item.Value["repeated"] = e.count
+				if e.firstSeen != nil {
+					e.item.Value["first_seen"] = e.firstSeen
+					e.item.Value["last_seen"] = e.lastSeen
+				}
+			}
+		}
+		return yield(e.item, nil)
+	}
+
+	// flush emits queued records, all of them or only those whose windows have expired by the specified time
+	flush := func(yield pipeline.Yield[JSON], all bool, now time.Time) bool {
+		for len(queue) > 0 {
+			head := queue[0]
+			if !all && head.group && !head.expires.Before(now) {
+				break
+			}
+			queue = queue[1:]
+			if !emit(head, yield) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			if obj.Metadata.Removed {
+				queue = append(queue, &collapseEntry{item: obj})
+				return flush(yield, false, time.Time{})
+			}
+
+			key, ok := collapseKey(obj.Value, paths)
+			if !ok {
+				// without a window the record ends the last group like any different record
+				queue = append(queue, &collapseEntry{item: obj})
+				return flush(yield, cfg.Window == 0, time.Time{})
+			}
+			t, hasTime := RecordTime(obj.Value, cfg.TimeProps)
+			rawTime := recordTimeValue(obj.Value, cfg.TimeProps)
+
+			if cfg.Window > 0 {
+				if hasTime && !flush(yield, false, t) {
+					return false
+				}
+			} else if _, ok := active[key]; !ok {
+				// only the last group is active without a window, a different record ends it
+				if !flush(yield, true, time.Time{}) {
+					return false
+				}
+			}
+
+			if g, ok := active[key]; ok {
+				g.count++
+				g.lastSeen = rawTime
+				obj.Metadata.Remove(opts, func() string {
+					return fmt.Sprintf("repeats record %s:%d", g.item.Metadata.FileName, g.item.Metadata.RecNum)
+				})
+				if opts.KeepRemoved {
+					queue = append(queue, &collapseEntry{item: obj})
+				}
+				return true
+			}
+
+			e := &collapseEntry{
+				item:      obj,
+				key:       key,
+				group:     true,
+				count:     1,
+				firstSeen: rawTime,
+				lastSeen:  rawTime,
+			}
+			if hasTime {
+				e.expires = t.Add(cfg.Window)
+			}
+			queue = append(queue, e)
+			active[key] = e
+			return true
+		},
+		func(yield pipeline.Yield[JSON]) {
+			flush(yield, true, time.Time{})
+		},
+	)
+}
+
+// collapseKey returns the key of the record and false if the record has none of the key properties.
+func collapseKey(obj JSON, paths [][]string) (string, bool) {
+	var sb strings.Builder
+	foundAny := false
+	for _, path := range paths {
+		found := false
+		ValuesByPath(obj, path, func(v any) bool {
+			found = true
+			fmt.Fprint(&sb, v)
+			return false
+		})
+		if !found {
+			sb.WriteByte(1)
+		}
+		foundAny = foundAny || found
+		sb.WriteByte(0)
+	}
+	return sb.String(), foundAny
+}
+
+func recordTimeValue(obj JSON, timeProps []string) any {
+	for _, p := range timeProps {
+		if v, ok := obj[p]; ok {
+			return v
+		}
+	}
+	return nil
+}
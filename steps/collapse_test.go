@@ -0,0 +1,78 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func TestCollapseConsecutive(t *testing.T) {
+	step := Collapse(pipeline.PipelineOptions{}, CollapseConfig{Fields: []string{"level", "msg"}, TimeProps: []string{"ts"}})
+	res := sampled(step(sliceToSeq([]JSON{
+		{"ts": "1", "level": "error", "msg": "refused"},
+		{"ts": "2", "level": "error", "msg": "refused"},
+		{"ts": "3", "level": "error", "msg": "refused"},
+		{"ts": "4", "level": "info", "msg": "retry"},
+		{"ts": "5", "level": "error", "msg": "refused"},
+	})))
+
+	assert.Equal(t, []JSON{
+		{"ts": "1", "level": "error", "msg": "refused", "repeated": 3, "first_seen": "1", "last_seen": "3"},
+		{"ts": "4", "level": "info", "msg": "retry"},
+		{"ts": "5", "level": "error", "msg": "refused"},
+	}, res)
+}
+
+func TestCollapseWindow(t *testing.T) {
+	ts := func(sec int) string {
+		return time.Date(2024, 1, 1, 0, 0, sec, 0, time.UTC).Format(time.RFC3339)
+	}
+	step := Collapse(pipeline.PipelineOptions{}, CollapseConfig{
+		Fields:    []string{"msg"},
+		Window:    10 * time.Second,
+		TimeProps: []string{"ts"},
+	})
+	res := sampled(step(sliceToSeq([]JSON{
+		{"ts": ts(0), "msg": "refused"},
+		{"ts": ts(1), "msg": "retry"},
+		{"ts": ts(2), "msg": "refused"},
+		{"ts": ts(9), "msg": "refused"},
+		{"ts": ts(11), "msg": "refused"},
+		{"ts": ts(12), "msg": "done"},
+	})))
+
+	assert.Equal(t, []JSON{
+		{"ts": ts(0), "msg": "refused", "repeated": 3, "first_seen": ts(0), "last_seen": ts(9)},
+		{"ts": ts(1), "msg": "retry"},
+		{"ts": ts(11), "msg": "refused"},
+		{"ts": ts(12), "msg": "done"},
+	}, res)
+}
+
+func TestCollapseKeepRemoved(t *testing.T) {
+	step := Collapse(pipeline.PipelineOptions{KeepRemoved: true}.Named("collapse"), CollapseConfig{Fields: []string{"msg"}})
+	var items []pipeline.Item[JSON]
+	for i, obj := range []JSON{{"msg": "a"}, {"msg": "a"}, {"msg": "b"}} {
+		items = append(items, pipeline.Item[JSON]{Value: obj, Metadata: pipeline.Metadata{FileName: "app.log", RecNum: i}})
+	}
+
+	var removedBy []string
+	for item, _ := range step(itemsToSeq(items)) {
+		removedBy = append(removedBy, item.Metadata.RemovedBy)
+	}
+	assert.Equal(t, []string{"", "collapse: repeats record app.log:0", ""}, removedBy)
+}
+
+func TestCollapseMissingFields(t *testing.T) {
+	step := Collapse(pipeline.PipelineOptions{}, CollapseConfig{Fields: []string{"level", "msg"}})
+	in := []JSON{
+		{"message": "a"},
+		{"message": "b"},
+		{"message": "c"},
+		{"level": "info"},
+		{"level": "info", "msg": ""},
+	}
+	assert.Equal(t, in, sampled(step(sliceToSeq(in))))
+}
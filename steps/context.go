@@ -3,6 +3,10 @@ package steps
 import "github.com/vladimir-rom/logex/pipeline"
 
 func Context(opts pipeline.PipelineOptions, countBefore, countAfter int) pipeline.Step[JSON, JSON] {
+	if opts.KeepRemoved {
+		return Noop[JSON]()
+	}
+
 	if !opts.ContextEnabled {
 		return pipeline.NewStep(
 			opts,
@@ -14,33 +18,56 @@ func Context(opts pipeline.PipelineOptions, countBefore, countAfter int) pipelin
 			})
 	}
 
+	if countBefore <= 0 && countAfter <= 0 {
+		// context records are selected by another step
+		return Noop[JSON]()
+	}
+
 	type rec struct {
 		json pipeline.Item[JSON]
 		err  error
 	}
-	buffer := newRingBuffer[rec](countBefore)
-	remaindedToWrite := 0
-	return pipeline.NewStep(
-		opts,
-		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+
+	// the step is applied to each input file concurrently, so the state is created for each file
+	return pipeline.NewStepPerSeq(opts, func() func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		var buffer *ringBuffer[rec]
+		if countBefore > 0 {
+			buffer = newRingBuffer[rec](countBefore)
+		}
+		remaindedToWrite := 0
+		lastRecNum := -1
+
+		// records not following the last written record start a new group
+		write := func(obj pipeline.Item[JSON], err error, yield pipeline.Yield[JSON]) bool {
+			obj.Metadata.GroupStart = lastRecNum >= 0 && obj.Metadata.RecNum != lastRecNum+1
+			lastRecNum = obj.Metadata.RecNum
+			return yield(obj, err)
+		}
+
+		return func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
 			if !obj.Metadata.Removed {
-				for item := range buffer.All {
-					if !yield(item.json, item.err) {
-						return false
+				if buffer != nil {
+					for item := range buffer.All {
+						if !write(item.json, item.err, yield) {
+							return false
+						}
 					}
+					buffer = newRingBuffer[rec](countBefore)
 				}
 
-				buffer = newRingBuffer[rec](countBefore)
 				remaindedToWrite = countAfter
-				return yield(obj, nil)
+				return write(obj, nil, yield)
 			}
 
 			if remaindedToWrite > 0 {
 				remaindedToWrite--
-				return yield(obj, nil)
+				return write(obj, nil, yield)
 			}
 
-			buffer.Add(rec{obj, nil})
+			if buffer != nil {
+				buffer.Add(rec{obj, nil})
+			}
 			return true
-		})
+		}
+	})
 }
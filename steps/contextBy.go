@@ -0,0 +1,119 @@
+package steps
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// ContextBy outputs, for each matched record, all records having the same value of the property, grouped together.
+// Groups are output in the order of their first matches after all records are read. Records preceding the first
+// match of their group are buffered, at most limit of them, the oldest ones are dropped when the limit is exceeded.
+// At most maxGroups groups are buffered, matches of new groups above it are output as they come with their buffered
+// records and a warning. Zero maxGroups means no limit.
+// It expects removed records to be passed through, context records are output as removed ones.
+func ContextBy(opts pipeline.PipelineOptions, property string, limit, maxGroups int) pipeline.Step[JSON, JSON] {
+	if len(property) == 0 {
+		return Noop[JSON]()
+	}
+
+	path := SplitPath(property)
+	pending := make(map[string][]pipeline.Item[JSON])
+	// pendingOrder contains keys of the pending records in the order of their arrival,
+	// keys of the records moved to groups are skipped
+	var pendingOrder []string
+	pendingCount := 0
+	groups := make(map[string][]pipeline.Item[JSON])
+	var groupsOrder []string
+	warned := false
+	// yielded is set when groups above maxGroups are output before the buffered ones
+	yielded := false
+
+	keyOf := func(obj JSON) (string, bool) {
+		key, found := "", false
+		ValuesByPath(obj, path, func(v any) bool {
+			key, found = fmt.Sprint(v), true
+			return false
+		})
+		return key, found
+	}
+
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			key, ok := keyOf(obj.Value)
+			if !ok {
+				if obj.Metadata.Removed {
+					return true
+				}
+				// a match without the property forms its own group
+				key = fmt.Sprintf("\x00%s:%d", obj.Metadata.FileName, obj.Metadata.RecNum)
+			}
+
+			if group, ok := groups[key]; ok {
+				groups[key] = append(group, obj)
+				return true
+			}
+
+			if !obj.Metadata.Removed && maxGroups > 0 && len(groups) >= maxGroups {
+				if !warned && opts.Warn != nil {
+					opts.Warn(fmt.Sprintf(
+						"context-by: more than %d groups, records of new groups are output as they come", maxGroups))
+				}
+				warned = true
+				group := append(pending[key], obj)
+				pendingCount -= len(pending[key])
+				delete(pending, key)
+				for i, item := range group {
+					item.Metadata.GroupStart = yielded && i == 0
+					if !yield(item, nil) {
+						return false
+					}
+					yielded = true
+				}
+				return true
+			}
+
+			if !obj.Metadata.Removed {
+				groups[key] = append(pending[key], obj)
+				groupsOrder = append(groupsOrder, key)
+				pendingCount -= len(pending[key])
+				delete(pending, key)
+				return true
+			}
+
+			pending[key] = append(pending[key], obj)
+			pendingOrder = append(pendingOrder, key)
+			pendingCount++
+			for pendingCount > limit {
+				oldest := pendingOrder[0]
+				pendingOrder = pendingOrder[1:]
+				if items, ok := pending[oldest]; ok {
+					pendingCount--
+					if len(items) > 1 {
+						pending[oldest] = items[1:]
+					} else {
+						delete(pending, oldest)
+					}
+				}
+			}
+			if len(pendingOrder) > 2*limit {
+				pendingOrder = slices.DeleteFunc(pendingOrder, func(k string) bool {
+					_, grouped := groups[k]
+					return grouped
+				})
+			}
+			return true
+		},
+		func(yield pipeline.Yield[JSON]) {
+			for i, key := range groupsOrder {
+				for j, item := range groups[key] {
+					item.Metadata.GroupStart = (i > 0 || yielded) && j == 0
+					if !yield(item, nil) {
+						return
+					}
+				}
+			}
+		})
+}
@@ -0,0 +1,50 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func TestContextBy(t *testing.T) {
+	records := []pipeline.Item[JSON]{
+		{Value: JSON{"trace": "a", "n": 0}, Metadata: pipeline.Metadata{Removed: true}},
+		{Value: JSON{"trace": "b", "n": 1}, Metadata: pipeline.Metadata{Removed: true}},
+		{Value: JSON{"trace": "a", "n": 2}, Metadata: pipeline.Metadata{Removed: true}},
+		{Value: JSON{"trace": "b", "n": 3}},
+		{Value: JSON{"trace": "c", "n": 4}, Metadata: pipeline.Metadata{Removed: true}},
+		{Value: JSON{"trace": "a", "n": 5}},
+		{Value: JSON{"trace": "b", "n": 6}, Metadata: pipeline.Metadata{Removed: true}},
+		{Value: JSON{"n": 7}},
+		{Value: JSON{"trace": "a", "n": 8}, Metadata: pipeline.Metadata{Removed: true}},
+	}
+
+	check := func(limit int, expected []int) {
+		t.Helper()
+		step := ContextBy(pipeline.PipelineOptions{ContextEnabled: true}, "trace", limit, 0)
+		var res []int
+		for item, _ := range step(itemsToSeq(records)) {
+			res = append(res, item.Value["n"].(int))
+		}
+		assert.Equal(t, expected, res)
+	}
+
+	check(100, []int{1, 3, 6, 0, 2, 5, 8, 7})
+	check(2, []int{1, 3, 6, 2, 5, 8, 7})
+
+	// groups above the limit are output as they come
+	var warnings []string
+	opts := pipeline.PipelineOptions{ContextEnabled: true, Warn: func(msg string) { warnings = append(warnings, msg) }}
+	var res []int
+	var starts []int
+	for item, _ := range ContextBy(opts, "trace", 100, 1)(itemsToSeq(records)) {
+		res = append(res, item.Value["n"].(int))
+		if item.Metadata.GroupStart {
+			starts = append(starts, item.Value["n"].(int))
+		}
+	}
+	assert.Equal(t, []int{0, 2, 5, 7, 1, 3, 6}, res)
+	assert.Equal(t, []int{7, 1}, starts)
+	assert.Equal(t, []string{"context-by: more than 1 groups, records of new groups are output as they come"}, warnings)
+}
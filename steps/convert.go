@@ -0,0 +1,208 @@
+package steps
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+type converter func(v any) (any, bool)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// byteUnits follows SI for decimal units (k, kb = 1000) and IEC for binary units (ki, kib = 1024)
+var byteUnits = map[string]float64{
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1e6,
+	"mb":  1e6,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1e9,
+	"gb":  1e9,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1e12,
+	"tb":  1e12,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"p":   1e15,
+	"pb":  1e15,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+}
+
+// newConverter creates a converter by its specification: kind[:unit],
+// where kind is duration (default unit ms), bytes (default unit B) or number.
+// The duration-ms kind converts durations to whole milliseconds like --duration-ms.
+func newConverter(spec string) (converter, error) {
+	kind, unit, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "duration-ms":
+		if len(unit) > 0 {
+			return nil, fmt.Errorf("duration-ms conversion does not support units: %s", spec)
+		}
+		return func(v any) (any, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, false
+			}
+			return d.Milliseconds(), true
+		}, nil
+
+	case "duration":
+		if len(unit) == 0 {
+			unit = "ms"
+		}
+		target, ok := durationUnits[unit]
+		if !ok {
+			return nil, fmt.Errorf("unknown duration unit: %s", unit)
+		}
+		return func(v any) (any, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, false
+			}
+			return float64(d) / float64(target), true
+		}, nil
+
+	case "bytes":
+		if len(unit) == 0 {
+			unit = "b"
+		}
+		target, ok := byteUnits[strings.ToLower(unit)]
+		if !ok {
+			return nil, fmt.Errorf("unknown bytes unit: %s", unit)
+		}
+		return func(v any) (any, bool) {
+			var b float64
+			switch val := v.(type) {
+			case string:
+				var err error
+				if b, err = parseBytes(val); err != nil {
+					return nil, false
+				}
+			case float64:
+				b = val
+			default:
+				return nil, false
+			}
+			return b / target, true
+		}, nil
+
+	case "number":
+		if len(unit) > 0 {
+			return nil, fmt.Errorf("number conversion does not support units: %s", spec)
+		}
+		return func(v any) (any, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			n, err := parseNumber(s)
+			return n, err == nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown conversion: %s", spec)
+	}
+}
+
+func parseBytes(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	unitStart := strings.LastIndexFunc(s, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.'
+	}) + 1
+	num, err := strconv.ParseFloat(strings.TrimSpace(s[:unitStart]), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	unit := strings.ToLower(strings.TrimSpace(s[unitStart:]))
+	if len(unit) == 0 {
+		return num, nil
+	}
+	multiplier, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown bytes unit: %s", unit)
+	}
+	return num * multiplier, nil
+}
+
+// parseNumber parses finite numbers only, NaN and infinities can not be written to JSON.
+func parseNumber(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("not a finite number: %s", s)
+	}
+	return f, nil
+}
+
+// Convert converts values of the specified properties, conversions maps property paths to converter specifications.
+func Convert(opts pipeline.PipelineOptions, conversions map[string]string) (pipeline.Step[JSON, JSON], error) {
+	if len(conversions) == 0 {
+		return Noop[JSON](), nil
+	}
+
+	type fieldConverter struct {
+		path    []string
+		convert converter
+	}
+	converters := make([]fieldConverter, 0, len(conversions))
+	fields := lo.Keys(conversions)
+	slices.Sort(fields)
+	for _, field := range fields {
+		c, err := newConverter(conversions[field])
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", field, err)
+		}
+		converters = append(converters, fieldConverter{SplitPath(field), c})
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		for _, c := range converters {
+			UpdateByPath(obj.Value, c.path, func(v any) any {
+				if converted, ok := c.convert(v); ok {
+					return converted
+				}
+				return v
+			})
+		}
+		return yield(obj, nil)
+	}), nil
+}
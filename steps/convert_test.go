@@ -0,0 +1,80 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"42", 42},
+		{"1.5 KB", 1500},
+		{"2k", 2000},
+		{"2Ki", 2048},
+		{"512KiB", 512 * 1024},
+		{"2M", 2e6},
+		{"2MB", 2e6},
+		{"2Mi", 2 * 1024 * 1024},
+		{"2MiB", 2 * 1024 * 1024},
+		{"1.2GB", 1.2e9},
+		{"1GiB", 1 << 30},
+		{"3T", 3e12},
+		{"1TiB", 1 << 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			b, err := parseBytes(tt.value)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, b, 1e-6)
+		})
+	}
+
+	_, err := parseBytes("12 parrots")
+	assert.Error(t, err)
+}
+
+func TestConverter(t *testing.T) {
+	tests := []struct {
+		spec     string
+		value    any
+		expected any
+	}{
+		{"duration", "1.5s", 1500.0},
+		{"duration:s", "1m30s", 90.0},
+		{"duration:us", "2ms", 2000.0},
+		{"duration-ms", "1.5s", int64(1500)},
+		{"duration-ms", "1.0009s", int64(1000)},
+		{"duration-ms", 42.0, 42.0},
+		{"bytes", "1KB", 1000.0},
+		{"bytes:KiB", "1MiB", 1024.0},
+		{"bytes:KB", "1MB", 1000.0},
+		{"bytes:MB", 5e5, 0.5},
+		{"number", "87%", 87.0},
+		{"number", "42", 42.0},
+		{"number", "not a number", "not a number"},
+		{"number", "NaN", "NaN"},
+		{"number", "inf", "inf"},
+		{"number", "-Infinity", "-Infinity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := newConverter(tt.spec)
+			require.NoError(t, err)
+			res, ok := c(tt.value)
+			if !ok {
+				res = tt.value
+			}
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+
+	for _, spec := range []string{"duration:days", "bytes:parrots", "number:ms", "duration-ms:s", "size"} {
+		_, err := newConverter(spec)
+		assert.Error(t, err, spec)
+	}
+}
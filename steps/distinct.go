@@ -0,0 +1,162 @@
+package steps
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// DistinctConfig describes removing of records with duplicate keys.
+type DistinctConfig struct {
+	// Fields are property paths forming the key, missing properties are a key value as well
+	Fields []string
+	// KeepLast keeps the last record with the key instead of the first one
+	KeepLast bool
+	// Count adds the 'count' field with the number of records with the key and 'first_seen'
+	// and 'last_seen' fields with their timestamps
+	Count bool
+	// CountPrefix is prepended to the names of the count fields to keep the record fields with the same names
+	CountPrefix string
+	// TimeProps are property names of the record time
+	TimeProps []string
+	// MaxKeys limits the number of remembered keys, records with new keys are output as they come
+	// without deduplication when the limit is reached. Zero means no limit.
+	MaxKeys int
+	// Warn is called once when MaxKeys is reached and once when the count fields replace record fields
+	Warn func(msg string)
+}
+
+type distinctEntry struct {
+	item      pipeline.Item[JSON]
+	seq       int
+	count     int
+	firstSeen any
+	lastSeen  any
+}
+
+// DistinctBy removes records with duplicate keys. The first records are output as they come, the last records
+// and records with counts are output after all records are read, in the order of their arrival.
+func DistinctBy(opts pipeline.PipelineOptions, cfg DistinctConfig) pipeline.Step[JSON, JSON] {
+	if len(cfg.Fields) == 0 {
+		return Noop[JSON]()
+	}
+
+	paths := make([][]string, len(cfg.Fields))
+	for i, f := range cfg.Fields {
+		paths[i] = SplitPath(f)
+	}
+	buffered := cfg.KeepLast || cfg.Count
+
+	countField, firstSeenField, lastSeenField := cfg.CountPrefix+"count", cfg.CountPrefix+"first_seen", cfg.CountPrefix+"last_seen"
+
+	entries := make(map[string]*distinctEntry)
+	warned := false
+	seq := 0
+
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			if obj.Metadata.Removed {
+				return yield(obj, nil)
+			}
+			seq++
+
+			key := distinctKey(obj.Value, paths)
+			e, ok := entries[key]
+			if !ok {
+				if cfg.MaxKeys > 0 && len(entries) >= cfg.MaxKeys {
+					if !warned && cfg.Warn != nil {
+						cfg.Warn(fmt.Sprintf(
+							"distinct-by: more than %d distinct keys, records with new keys are output without deduplication", cfg.MaxKeys))
+					}
+					warned = true
+					return yield(obj, nil)
+				}
+
+				rawTime := recordTimeValue(obj.Value, cfg.TimeProps)
+				e = &distinctEntry{item: obj, seq: seq, count: 1, firstSeen: rawTime, lastSeen: rawTime}
+				entries[key] = e
+				if buffered {
+					return true
+				}
+				return yield(obj, nil)
+			}
+
+			e.count++
+			if cfg.Count {
+				e.lastSeen = recordTimeValue(obj.Value, cfg.TimeProps)
+			}
+			duplicate := obj
+			if cfg.KeepLast {
+				duplicate, e.item, e.seq = e.item, obj, seq
+			}
+			kept := e.item.Metadata
+			duplicate.Metadata.Remove(opts, func() string {
+				return fmt.Sprintf("duplicate of record %s:%d with %s", kept.FileName, kept.RecNum, describeKey(cfg.Fields, duplicate.Value, paths))
+			})
+			return yield(duplicate, nil)
+		},
+		func(yield pipeline.Yield[JSON]) {
+			if !buffered {
+				return
+			}
+
+			kept := make([]*distinctEntry, 0, len(entries))
+			for _, e := range entries {
+				kept = append(kept, e)
+			}
+			slices.SortFunc(kept, func(a, b *distinctEntry) int { return a.seq - b.seq })
+
+			replaced := 0
+			for _, e := range kept {
+				if cfg.Count {
+					if _, ok := e.item.Value[countField]; ok {
+						replaced++
+					}
+					e.item.Value[countField] = e.count
+					if e.firstSeen != nil {
+						e.item.Value[firstSeenField] = e.firstSeen
+						e.item.Value[lastSeenField] = e.lastSeen
+					}
+				}
+				if !yield(e.item, nil) {
+					return
+				}
+			}
+			if replaced > 0 && cfg.Warn != nil {
+				cfg.Warn(fmt.Sprintf("distinct-by: the %s field of %d records is replaced by the number of records, use a count prefix to keep it",
+					countField, replaced))
+			}
+		})
+}
+
+func distinctKey(obj JSON, paths [][]string) string {
+	var sb strings.Builder
+	for _, path := range paths {
+		found := false
+		ValuesByPath(obj, path, func(v any) bool {
+			found = true
+			fmt.Fprintf(&sb, "%T:%v", v, v)
+			return false
+		})
+		if !found {
+			sb.WriteByte(1)
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+func describeKey(fields []string, obj JSON, paths [][]string) string {
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		parts[i] = fields[i] + ":<missing>"
+		ValuesByPath(obj, path, func(v any) bool {
+			parts[i] = fmt.Sprintf("%s:%v", fields[i], v)
+			return false
+		})
+	}
+	return strings.Join(parts, " ")
+}
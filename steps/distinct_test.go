@@ -0,0 +1,115 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+var distinctRecords = []JSON{
+	{"ts": "1", "user": "alice", "req": JSON{"path": "/a"}},
+	{"ts": "2", "user": "bob", "req": JSON{"path": "/a"}},
+	{"ts": "3", "user": "alice", "req": JSON{"path": "/b"}},
+	{"ts": "4", "user": "alice", "req": JSON{"path": "/a"}},
+	{"ts": "5", "req": JSON{"path": "/a"}},
+	{"ts": "6", "req": JSON{"path": "/a"}},
+}
+
+func TestDistinctByFirst(t *testing.T) {
+	step := DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{Fields: []string{"user", "req.path"}})
+	in := distinctRecords
+	res := sampled(step(sliceToSeq(in)))
+	assert.Equal(t, []JSON{in[0], in[1], in[2], in[4]}, res)
+}
+
+func TestDistinctByLastWithCount(t *testing.T) {
+	step := DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{
+		Fields:    []string{"user"},
+		KeepLast:  true,
+		Count:     true,
+		TimeProps: []string{"ts"},
+	})
+	res := sampled(step(sliceToSeq(cloneRecords(distinctRecords))))
+	assert.Equal(t, []JSON{
+		{"ts": "2", "user": "bob", "req": JSON{"path": "/a"}, "count": 1, "first_seen": "2", "last_seen": "2"},
+		{"ts": "4", "user": "alice", "req": JSON{"path": "/a"}, "count": 3, "first_seen": "1", "last_seen": "4"},
+		{"ts": "6", "req": JSON{"path": "/a"}, "count": 2, "first_seen": "5", "last_seen": "6"},
+	}, res)
+}
+
+func TestDistinctByLimit(t *testing.T) {
+	var warnings []string
+	step := DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{
+		Fields:  []string{"req.path"},
+		MaxKeys: 1,
+		Warn:    func(msg string) { warnings = append(warnings, msg) },
+	})
+	in := distinctRecords
+	res := sampled(step(sliceToSeq(in)))
+	assert.Equal(t, []JSON{in[0], in[2]}, res)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "more than 1 distinct keys")
+}
+
+func TestDistinctByLimitKeepLast(t *testing.T) {
+	step := DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{
+		Fields:   []string{"req.path"},
+		KeepLast: true,
+		MaxKeys:  1,
+	})
+	in := distinctRecords
+
+	// the record above the limit is output as it comes, before the buffered ones
+	var res []JSON
+	for item, _ := range step(sliceToSeq(in)) {
+		if !item.Metadata.Removed {
+			res = append(res, item.Value)
+		}
+	}
+	assert.Equal(t, []JSON{in[2], in[5]}, res)
+}
+
+func TestDistinctByCountPrefix(t *testing.T) {
+	in := []JSON{
+		{"user": "alice", "count": 7},
+		{"user": "alice", "count": 8},
+	}
+
+	var warnings []string
+	step := DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{
+		Fields: []string{"user"},
+		Count:  true,
+		Warn:   func(msg string) { warnings = append(warnings, msg) },
+	})
+	assert.Equal(t, []JSON{{"user": "alice", "count": 2}}, sampled(step(sliceToSeq(in))))
+	assert.Equal(t, []string{"distinct-by: the count field of 1 records is replaced by the number of records, use a count prefix to keep it"}, warnings)
+
+	in = []JSON{
+		{"user": "alice", "count": 7},
+		{"user": "alice", "count": 8},
+	}
+	warnings = nil
+	step = DistinctBy(pipeline.PipelineOptions{}, DistinctConfig{
+		Fields:      []string{"user"},
+		Count:       true,
+		CountPrefix: "distinct_",
+		Warn:        func(msg string) { warnings = append(warnings, msg) },
+	})
+	assert.Equal(t, []JSON{{"user": "alice", "count": 7, "distinct_count": 2}}, sampled(step(sliceToSeq(in))))
+	assert.Empty(t, warnings)
+}
+
+func TestDistinctByRemovalReason(t *testing.T) {
+	step := DistinctBy(pipeline.PipelineOptions{KeepRemoved: true}.Named("distinct-by"), DistinctConfig{Fields: []string{"user"}})
+	var items []pipeline.Item[JSON]
+	for i, obj := range distinctRecords[:4] {
+		items = append(items, pipeline.Item[JSON]{Value: obj, Metadata: pipeline.Metadata{FileName: "app.log", RecNum: i}})
+	}
+
+	var reasons []string
+	for item, _ := range step(itemsToSeq(items)) {
+		reasons = append(reasons, item.Metadata.RemovedBy)
+	}
+	assert.Equal(t, []string{"", "", "distinct-by: duplicate of record app.log:0 with user:alice", "distinct-by: duplicate of record app.log:0 with user:alice"}, reasons)
+}
@@ -0,0 +1,267 @@
+package steps
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// exactDistinctLimit is the number of distinct values counted exactly, larger numbers are estimated
+	exactDistinctLimit = 1000
+	// fieldValuesLimit is the maximum number of distinct values reported in FieldInfo.Values
+	fieldValuesLimit = 20
+	hllPrecision     = 12
+)
+
+// FieldInfo describes a property observed in records.
+type FieldInfo struct {
+	// Path is the property path with nested properties separated by dots,
+	// properties of objects in arrays have the path of the array
+	Path string
+	// Types are the observed JSON types: string, number, boolean, null, object and array
+	Types []string
+	// Count is the number of records containing the property
+	Count int
+	// Distinct is the number of distinct scalar values, estimated if DistinctExact is false
+	Distinct      int
+	DistinctExact bool
+	// Examples are the first distinct scalar values
+	Examples []any
+	// Values are all distinct scalar values if there are not many of them
+	Values []any
+	// Conversion is the conversion all string values can be converted with: number, duration or bytes.
+	// Identifiers like id and user_id are not converted to numbers.
+	Conversion string
+}
+
+type fieldStats struct {
+	path       string
+	types      []string
+	count      int
+	exact      map[string]any
+	order      []string
+	hll        *hyperLogLog
+	strings    int
+	numbers    int
+	durations  int
+	sizes      int
+	lastRecord int
+}
+
+// FieldInventory collects the properties of records with their types, presence and cardinality.
+type FieldInventory struct {
+	examples int
+	records  int
+	fields   map[string]*fieldStats
+}
+
+func NewFieldInventory(examples int) *FieldInventory {
+	return &FieldInventory{
+		examples: examples,
+		fields:   make(map[string]*fieldStats),
+	}
+}
+
+func (inv *FieldInventory) Add(obj JSON) {
+	inv.records++
+	inv.walk("", obj)
+}
+
+// Records returns the number of added records.
+func (inv *FieldInventory) Records() int {
+	return inv.records
+}
+
+func (inv *FieldInventory) walk(prefix string, obj map[string]any) {
+	for k, v := range obj {
+		path := k
+		if len(prefix) > 0 {
+			path = prefix + "." + k
+		}
+		inv.observe(path, v)
+	}
+}
+
+func (inv *FieldInventory) observe(path string, v any) {
+	f, ok := inv.fields[path]
+	if !ok {
+		f = &fieldStats{path: path, exact: make(map[string]any)}
+		inv.fields[path] = f
+	}
+	if f.lastRecord != inv.records {
+		// properties of objects in arrays are counted once per record
+		f.lastRecord = inv.records
+		f.count++
+	}
+
+	typ := jsonTypeName(v)
+	if !slices.Contains(f.types, typ) {
+		f.types = append(f.types, typ)
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		inv.walk(path, val)
+	case JSON:
+		inv.walk(path, val)
+	case []any:
+		for _, item := range val {
+			switch obj := item.(type) {
+			case map[string]any:
+				inv.walk(path, obj)
+			case JSON:
+				inv.walk(path, obj)
+			}
+		}
+	default:
+		f.addValue(v)
+	}
+}
+
+func (f *fieldStats) addValue(v any) {
+	key := fmt.Sprintf("%T:%v", v, v)
+	if f.hll == nil {
+		if _, ok := f.exact[key]; !ok {
+			f.exact[key] = v
+			f.order = append(f.order, key)
+		}
+		if len(f.exact) > exactDistinctLimit {
+			f.hll = &hyperLogLog{}
+			for k := range f.exact {
+				f.hll.add(k)
+			}
+		}
+	} else {
+		f.hll.add(key)
+	}
+
+	if s, ok := v.(string); ok {
+		f.strings++
+		if _, err := parseNumber(s); err == nil {
+			f.numbers++
+		}
+		if _, err := time.ParseDuration(s); err == nil {
+			f.durations++
+		}
+		if _, err := parseBytes(s); err == nil && strings.IndexFunc(s, isLetter) >= 0 {
+			f.sizes++
+		}
+	}
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// isIDField reports whether the property is an identifier: id or *_id.
+func isIDField(path string) bool {
+	name := strings.ToLower(path[strings.LastIndexByte(path, '.')+1:])
+	return name == "id" || strings.HasSuffix(name, "_id")
+}
+
+func (f *fieldStats) info(examples int) FieldInfo {
+	res := FieldInfo{
+		Path:          f.path,
+		Types:         slices.Clone(f.types),
+		Count:         f.count,
+		Distinct:      len(f.exact),
+		DistinctExact: f.hll == nil,
+	}
+	slices.Sort(res.Types)
+	if f.hll != nil {
+		res.Distinct = f.hll.estimate()
+	}
+
+	for _, key := range f.order[:min(examples, len(f.order))] {
+		res.Examples = append(res.Examples, f.exact[key])
+	}
+	if res.DistinctExact && res.Distinct <= fieldValuesLimit {
+		for _, key := range f.order {
+			res.Values = append(res.Values, f.exact[key])
+		}
+	}
+
+	if f.strings > 0 {
+		switch f.strings {
+		case f.numbers:
+			if !isIDField(f.path) {
+				res.Conversion = "number"
+			}
+		case f.durations:
+			res.Conversion = "duration"
+		case f.sizes:
+			res.Conversion = "bytes"
+		}
+	}
+	return res
+}
+
+// Fields returns the observed properties sorted by the number of records containing them and by path.
+func (inv *FieldInventory) Fields() []FieldInfo {
+	res := make([]FieldInfo, 0, len(inv.fields))
+	for _, f := range inv.fields {
+		res = append(res, f.info(inv.examples))
+	}
+	slices.SortFunc(res, func(a, b FieldInfo) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return res
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]any, JSON:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return "number"
+	}
+}
+
+// hyperLogLog estimates the number of distinct values in constant memory.
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(key string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	x := mix(hash.Sum64())
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() int {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// linear counting is more accurate for small cardinalities
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(e))
+}
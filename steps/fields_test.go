@@ -0,0 +1,86 @@
+package steps
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldInventory(t *testing.T) {
+	inv := NewFieldInventory(2)
+	for _, obj := range []JSON{
+		{"level": "info", "dur": "5ms", "req": JSON{"id": float64(1), "tags": []any{JSON{"k": "a"}, JSON{"k": "b"}}}},
+		{"level": "error", "dur": "1.5s", "req": JSON{"id": "2"}, "size": "10KB"},
+		{"level": "info", "dur": "15ms", "size": "1MB", "ok": true, "code": nil},
+	} {
+		inv.Add(obj)
+	}
+
+	fields := inv.Fields()
+	byPath := make(map[string]FieldInfo, len(fields))
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		byPath[f.Path] = f
+		paths[i] = f.Path
+	}
+
+	assert.Equal(t, 3, inv.Records())
+	assert.Equal(t, []string{"dur", "level", "req", "req.id", "size", "code", "ok", "req.tags", "req.tags.k"}, paths)
+
+	assert.Equal(t, FieldInfo{
+		Path: "level", Types: []string{"string"}, Count: 3, Distinct: 2, DistinctExact: true,
+		Examples: []any{"info", "error"}, Values: []any{"info", "error"},
+	}, byPath["level"])
+	assert.Equal(t, []string{"number", "string"}, byPath["req.id"].Types)
+	assert.Equal(t, []string{"object"}, byPath["req"].Types)
+	assert.Equal(t, 0, byPath["req"].Distinct)
+	assert.Equal(t, []string{"null"}, byPath["code"].Types)
+	assert.Equal(t, []string{"boolean"}, byPath["ok"].Types)
+
+	// properties of objects in arrays are counted once per record
+	assert.Equal(t, 1, byPath["req.tags.k"].Count)
+	assert.Equal(t, 2, byPath["req.tags.k"].Distinct)
+
+	assert.Equal(t, "duration", byPath["dur"].Conversion)
+	assert.Equal(t, "bytes", byPath["size"].Conversion)
+	assert.Equal(t, "", byPath["level"].Conversion)
+	// numeric identifiers are not numbers
+	assert.Equal(t, "", byPath["req.id"].Conversion)
+}
+
+func TestFieldInventoryNumberConversion(t *testing.T) {
+	inv := NewFieldInventory(3)
+	inv.Add(JSON{"n": "1"})
+	inv.Add(JSON{"n": "2.5"})
+
+	fields := inv.Fields()
+	require.Len(t, fields, 1)
+	assert.Equal(t, "number", fields[0].Conversion)
+
+	for _, path := range []string{"id", "user_id", "req.ID", "req.span_id"} {
+		inv := NewFieldInventory(3)
+		inv.Add(JSON{path: "42"})
+		assert.Equal(t, "", inv.Fields()[0].Conversion, path)
+	}
+}
+
+func TestFieldInventoryEstimate(t *testing.T) {
+	inv := NewFieldInventory(3)
+	for i := range 50000 {
+		inv.Add(JSON{"id": fmt.Sprintf("id%d", i), "level": "info"})
+	}
+
+	fields := inv.Fields()
+	require.Len(t, fields, 2)
+	id := fields[0]
+	assert.Equal(t, "id", id.Path)
+	assert.False(t, id.DistinctExact)
+	assert.InDelta(t, 50000, id.Distinct, 2500)
+	assert.Equal(t, []any{"id0", "id1", "id2"}, id.Examples)
+	assert.Nil(t, id.Values)
+
+	assert.Equal(t, 1, fields[1].Distinct)
+	assert.True(t, fields[1].DistinctExact)
+}
@@ -1,6 +1,8 @@
 package steps
 
 import (
+	"fmt"
+
 	"github.com/vladimir-rom/logex/pipeline"
 )
 
@@ -8,6 +10,9 @@ func Last(opts pipeline.PipelineOptions, count int) pipeline.Step[JSON, JSON] {
 	if count <= 0 {
 		return Noop[JSON]()
 	}
+	if opts.KeepRemoved {
+		return lastKeepRemoved(opts, count)
+	}
 
 	type rec struct {
 		json pipeline.Item[JSON]
@@ -31,6 +36,42 @@ func Last(opts pipeline.PipelineOptions, count int) pipeline.Step[JSON, JSON] {
 	)
 }
 
+// lastKeepRemoved outputs all records in their order, the records before the last ones are removed.
+func lastKeepRemoved(opts pipeline.PipelineOptions, count int) pipeline.Step[JSON, JSON] {
+	// queue contains the last records and the removed records between them
+	var queue []pipeline.Item[JSON]
+	kept := 0
+
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			queue = append(queue, obj)
+			if !obj.Metadata.Removed {
+				kept++
+			}
+			for len(queue) > 0 && (kept > count || queue[0].Metadata.Removed) {
+				item := queue[0]
+				queue = queue[1:]
+				if !item.Metadata.Removed {
+					kept--
+					item.Metadata.Remove(opts, func() string { return fmt.Sprintf("before the last %d records", count) })
+				}
+				if !yield(item, nil) {
+					return false
+				}
+			}
+			return true
+		},
+		func(yield pipeline.Yield[JSON]) {
+			for _, item := range queue {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		},
+	)
+}
+
 func First(opts pipeline.PipelineOptions, count int) pipeline.Step[JSON, JSON] {
 	if count <= 0 {
 		return Noop[JSON]()
@@ -38,9 +79,16 @@ func First(opts pipeline.PipelineOptions, count int) pipeline.Step[JSON, JSON] {
 
 	returned := 0
 	return pipeline.NewStep[JSON, JSON](opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed && opts.KeepRemoved {
+			return yield(obj, nil)
+		}
 		returned++
 		if returned > count {
-			return false
+			if !opts.KeepRemoved {
+				return false
+			}
+			// the rest of the records are read to explain their removal
+			obj.Metadata.Remove(opts, func() string { return fmt.Sprintf("after the first %d records", count) })
 		}
 
 		return yield(obj, nil)
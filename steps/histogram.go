@@ -0,0 +1,122 @@
+package steps
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HistogramMissing is the split value of records without the split property.
+const HistogramMissing = "(missing)"
+
+// maxHistogramBuckets limits the number of buckets between the first and the last record.
+const maxHistogramBuckets = 100000
+
+// HistogramBucket contains the number of records in a time interval.
+type HistogramBucket struct {
+	Start time.Time
+	Count int
+	// Counts contains the numbers of records by the values of the split property
+	Counts map[string]int
+}
+
+// Histogram counts records in time intervals, optionally split by the values of a property.
+type Histogram struct {
+	interval  time.Duration
+	timeProps []string
+	splitPath []string
+	buckets   map[int64]*HistogramBucket
+	totals    map[string]int
+	skipped   int
+}
+
+func NewHistogram(interval time.Duration, timeProps []string, splitBy string) *Histogram {
+	h := &Histogram{
+		interval:  interval,
+		timeProps: timeProps,
+		buckets:   make(map[int64]*HistogramBucket),
+		totals:    make(map[string]int),
+	}
+	if len(splitBy) > 0 {
+		h.splitPath = SplitPath(splitBy)
+	}
+	return h
+}
+
+// Add counts the record, records without a timestamp are skipped.
+func (h *Histogram) Add(obj JSON) {
+	t, ok := RecordTime(obj, h.timeProps)
+	if !ok {
+		h.skipped++
+		return
+	}
+
+	start := t.UTC().Truncate(h.interval)
+	b, ok := h.buckets[start.UnixNano()]
+	if !ok {
+		b = &HistogramBucket{Start: start}
+		h.buckets[start.UnixNano()] = b
+	}
+	b.Count++
+
+	if h.splitPath != nil {
+		value := HistogramMissing
+		ValuesByPath(obj, h.splitPath, func(v any) bool {
+			value = fmt.Sprint(v)
+			return false
+		})
+		if b.Counts == nil {
+			b.Counts = make(map[string]int)
+		}
+		b.Counts[value]++
+		h.totals[value]++
+	}
+}
+
+// Buckets returns the buckets from the first record to the last one including empty buckets.
+func (h *Histogram) Buckets() ([]HistogramBucket, error) {
+	if len(h.buckets) == 0 {
+		return nil, nil
+	}
+
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for k := range h.buckets {
+		first, last = min(first, k), max(last, k)
+	}
+	n := (last-first)/int64(h.interval) + 1
+	if n > maxHistogramBuckets {
+		return nil, fmt.Errorf("histogram has %d buckets, the maximum is %d, use a larger interval", n, maxHistogramBuckets)
+	}
+
+	res := make([]HistogramBucket, 0, n)
+	for k := first; k <= last; k += int64(h.interval) {
+		if b, ok := h.buckets[k]; ok {
+			res = append(res, *b)
+		} else {
+			res = append(res, HistogramBucket{Start: time.Unix(0, k).UTC()})
+		}
+	}
+	return res, nil
+}
+
+// Values returns the values of the split property sorted by the number of records.
+func (h *Histogram) Values() []string {
+	res := make([]string, 0, len(h.totals))
+	for v := range h.totals {
+		res = append(res, v)
+	}
+	slices.SortFunc(res, func(a, b string) int {
+		if h.totals[a] != h.totals[b] {
+			return h.totals[b] - h.totals[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return res
+}
+
+// Skipped returns the number of records without a timestamp.
+func (h *Histogram) Skipped() int {
+	return h.skipped
+}
@@ -0,0 +1,40 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(time.Minute, []string{"ts"}, "level")
+	for _, obj := range []JSON{
+		{"ts": "2024-03-01T10:00:10Z", "level": "error"},
+		{"ts": "2024-03-01T12:00:50+02:00", "level": "info"},
+		{"ts": float64(1709287390), "level": "info"},
+		{"ts": "2024-03-01T10:00:30Z"},
+		{"level": "info"},
+	} {
+		h.Add(obj)
+	}
+
+	buckets, err := h.Buckets()
+	require.NoError(t, err)
+	minute := func(m int) time.Time { return time.Date(2024, 3, 1, 10, m, 0, 0, time.UTC) }
+	assert.Equal(t, []HistogramBucket{
+		{Start: minute(0), Count: 3, Counts: map[string]int{"error": 1, "info": 1, HistogramMissing: 1}},
+		{Start: minute(1)},
+		{Start: minute(2)},
+		{Start: minute(3), Count: 1, Counts: map[string]int{"info": 1}},
+	}, buckets)
+	assert.Equal(t, []string{"info", HistogramMissing, "error"}, h.Values())
+	assert.Equal(t, 1, h.Skipped())
+
+	h = NewHistogram(time.Second, []string{"ts"}, "")
+	h.Add(JSON{"ts": "2024-03-01T10:00:00Z"})
+	h.Add(JSON{"ts": "2024-03-03T10:00:00Z"})
+	_, err = h.Buckets()
+	assert.ErrorContains(t, err, "use a larger interval")
+}
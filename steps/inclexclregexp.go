@@ -31,7 +31,7 @@ func IncludeRegexp(opts pipeline.PipelineOptions, regexps []string) (pipeline.St
 				return yield(line, nil)
 			}
 		}
-		line.Metadata.Removed = true
+		line.Metadata.Remove(opts, func() string { return fmt.Sprintf("does not match any of %q", regexps) })
 		return yield(line, nil)
 	}), nil
 }
@@ -57,7 +57,7 @@ func ExcludeRegexp(opts pipeline.PipelineOptions, regexps []string) (pipeline.St
 
 		for _, r := range rs {
 			if r.MatchString(line.Value) {
-				line.Metadata.Removed = true
+				line.Metadata.Remove(opts, func() string { return fmt.Sprintf("matches %q", r) })
 				return yield(line, nil)
 			}
 
@@ -2,19 +2,82 @@ package steps
 
 import (
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
 	"github.com/itchyny/gojq"
+	"github.com/samber/lo"
 	"github.com/vladimir-rom/logex/pipeline"
 )
 
-func FilterByJq(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JSON, JSON], error) {
+// JqEnv is the environment of jq programs.
+type JqEnv struct {
+	// Vars are values of named arguments available in programs as $name
+	Vars map[string]any
+	// LibraryPaths are paths to search modules imported by programs
+	LibraryPaths []string
+}
+
+// CompileJq compiles the jq program with the environment and additional functions:
+// totime converts timestamps in the supported formats to unix time in seconds,
+// toduration converts duration strings like "1m30s" to seconds.
+// It returns the compiled program and the values of its variables.
+func CompileJq(filter string, env JqEnv, options ...gojq.CompilerOption) (*gojq.Code, []any, error) {
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filter parsing error: %w", err)
+	}
+
+	names := lo.Keys(env.Vars)
+	slices.Sort(names)
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = env.Vars[name]
+		names[i] = "$" + name
+	}
+
+	options = append(options,
+		gojq.WithVariables(names),
+		gojq.WithModuleLoader(gojq.NewModuleLoader(env.LibraryPaths)),
+		gojq.WithEnvironLoader(os.Environ),
+		gojq.WithFunction("totime", 0, 0, func(v any, _ []any) any {
+			t, ok := ParseTime(v)
+			if !ok {
+				return fmt.Errorf("totime cannot parse %v", v)
+			}
+			return float64(t.UnixNano()) / 1e9
+		}),
+		gojq.WithFunction("toduration", 0, 0, func(v any, _ []any) any {
+			switch val := v.(type) {
+			case string:
+				d, err := time.ParseDuration(val)
+				if err != nil {
+					return fmt.Errorf("toduration cannot parse %v", v)
+				}
+				return d.Seconds()
+			case int, float64:
+				return val
+			default:
+				return fmt.Errorf("toduration cannot parse %v", v)
+			}
+		}))
+
+	code, err := gojq.Compile(query, options...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filter compilation error: %w", err)
+	}
+	return code, values, nil
+}
+
+func FilterByJq(opts pipeline.PipelineOptions, filter string, env JqEnv) (pipeline.Step[JSON, JSON], error) {
 	if len(filter) == 0 {
 		return Noop[JSON](), nil
 	}
 
-	expression, err := gojq.Parse(filter)
+	code, values, err := CompileJq(filter, env)
 	if err != nil {
-		return nil, fmt.Errorf("filter parsing error: %w", err)
+		return nil, err
 	}
 
 	return pipeline.NewStep[JSON, JSON](opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
@@ -22,7 +85,7 @@ func FilterByJq(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JSO
 			return yield(obj, nil)
 		}
 
-		iter := expression.Run(map[string]any(obj.Value))
+		iter := code.Run(map[string]any(obj.Value), slices.Clone(values)...)
 		for {
 			v, ok := iter.Next()
 			if !ok {
@@ -34,7 +97,9 @@ func FilterByJq(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JSO
 					return false
 				}
 			case bool:
-				obj.Metadata.Removed = !item
+				if !item {
+					obj.Metadata.Remove(opts, func() string { return "returned false" })
+				}
 				if !yield(obj, nil) {
 					return false
 				}
@@ -52,3 +117,47 @@ func FilterByJq(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JSO
 		return true
 	}), nil
 }
+
+// SlurpJq runs the jq program once with the array of all records as input, its results are output as records.
+func SlurpJq(opts pipeline.PipelineOptions, filter string, env JqEnv) (pipeline.Step[JSON, JSON], error) {
+	if len(filter) == 0 {
+		return Noop[JSON](), nil
+	}
+
+	code, values, err := CompileJq(filter, env)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]any, 0)
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			if !obj.Metadata.Removed {
+				records = append(records, map[string]any(obj.Value))
+			}
+			return true
+		},
+		func(yield pipeline.Yield[JSON]) {
+			iter := code.Run(records, values...)
+			for {
+				v, ok := iter.Next()
+				if !ok {
+					return
+				}
+				var res pipeline.Item[JSON]
+				var err error
+				switch item := v.(type) {
+				case error:
+					err = item
+				case map[string]any:
+					res.Value = item
+				default:
+					res.Value = JSON{"item": item}
+				}
+				if !yield(res, err) {
+					return
+				}
+			}
+		}), nil
+}
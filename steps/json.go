@@ -1,6 +1,9 @@
 package steps
 
-import "sort"
+import (
+	"sort"
+	"strings"
+)
 
 type JSON map[string]any
 
@@ -25,3 +28,87 @@ func (j JSON) SortedByKey(yield func(key string, val any) bool) {
 		}
 	}
 }
+
+// ValuesByPath yields values located by the path of property names.
+// Arrays met on the way are traversed and each of their elements is processed,
+// a property name containing dots is matched as is before being treated as a nested path.
+func ValuesByPath(value any, path []string, yield func(v any) bool) bool {
+	if arr, ok := value.([]any); ok {
+		for _, item := range arr {
+			if !ValuesByPath(item, path, yield) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if len(path) == 0 {
+		return yield(value)
+	}
+
+	var obj map[string]any
+	switch v := value.(type) {
+	case JSON:
+		obj = v
+	case map[string]any:
+		obj = v
+	default:
+		return true
+	}
+
+	for i := len(path); i > 0; i-- {
+		key := path[0]
+		if i > 1 {
+			key = strings.Join(path[:i], ".")
+		}
+		if v, ok := obj[key]; ok {
+			return ValuesByPath(v, path[i:], yield)
+		}
+	}
+	return true
+}
+
+func SplitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// UpdateByPath replaces values located by the path with the results of the update function.
+func UpdateByPath(value any, path []string, update func(v any) any) {
+	if len(path) == 0 {
+		return
+	}
+
+	var obj map[string]any
+	switch v := value.(type) {
+	case JSON:
+		obj = v
+	case map[string]any:
+		obj = v
+	case []any:
+		for _, item := range v {
+			UpdateByPath(item, path, update)
+		}
+		return
+	default:
+		return
+	}
+
+	for i := len(path); i > 0; i-- {
+		key := path[0]
+		if i > 1 {
+			key = strings.Join(path[:i], ".")
+		}
+		if v, ok := obj[key]; ok {
+			if i < len(path) {
+				UpdateByPath(v, path[i:], update)
+			} else if arr, ok := v.([]any); ok {
+				for j := range arr {
+					arr[j] = update(arr[j])
+				}
+			} else {
+				obj[key] = update(v)
+			}
+			return
+		}
+	}
+}
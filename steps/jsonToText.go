@@ -19,7 +19,9 @@ func JsonToText(
 	noProp bool,
 	textDelim string,
 	highlights []string,
-	propertiesConfig config.Properties) (pipeline.Step[JSON, string], error) {
+	groupSeparator string,
+	propertiesConfig config.Properties,
+	colorBuilder colors.ColorBuilder) (pipeline.Step[JSON, string], error) {
 	propsMap := make(map[string]struct{})
 	for _, p := range headProps {
 		propsMap[p] = struct{}{}
@@ -28,7 +30,7 @@ func JsonToText(
 		propsMap[p] = struct{}{}
 	}
 
-	c, err := colors.NewColorizer(propertiesConfig, colors.DefaultColorBuilder)
+	c, err := colors.NewColorizer(propertiesConfig, colorBuilder)
 	if err != nil {
 		return nil, err
 	}
@@ -69,14 +71,35 @@ func JsonToText(
 			return true
 		}
 
+		switch {
+		case obj.Metadata.Removed && opts.KeepRemoved:
+			outStr = c.Removed(outStr + " <- removed by " + obj.Metadata.RemovedBy)
+		case obj.Metadata.Removed && opts.ContextEnabled:
+			outStr = contextMarker + c.Context(outStr)
+		case opts.ContextEnabled:
+			outStr = matchMarker + highlighter(outStr)
+		default:
+			outStr = highlighter(outStr)
+		}
+
+		if obj.Metadata.GroupStart && len(groupSeparator) > 0 {
+			outStr = groupSeparator + "\n" + outStr
+		}
+
 		if !noNewLine {
 			outStr += "\n"
 		}
 
-		return yield(pipeline.ToItem[JSON, string](obj, highlighter(outStr)), nil)
+		return yield(pipeline.ToItem[JSON, string](obj, outStr), nil)
 	}), nil
 }
 
+// markers distinguishing matched records from context records
+const (
+	matchMarker   = "> "
+	contextMarker = "  "
+)
+
 func getHighlighter(subs []string, c *colors.Colorizer) func(string) string {
 	if !c.Enabled || len(subs) == 0 {
 		return func(s string) string {
@@ -0,0 +1,33 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesByPath(t *testing.T) {
+	obj := JSON{
+		"a":     map[string]any{"b": "v1"},
+		"arr":   []any{map[string]any{"c": "v2"}, map[string]any{"c": "v3"}},
+		"d.e":   "v4",
+		"leafs": []any{"v5", "v6"},
+	}
+
+	checkValuesByPath(t, obj, "a.b", "v1")
+	checkValuesByPath(t, obj, "arr.c", "v2", "v3")
+	checkValuesByPath(t, obj, "d.e", "v4")
+	checkValuesByPath(t, obj, "leafs", "v5", "v6")
+	checkValuesByPath(t, obj, "a.x")
+	checkValuesByPath(t, obj, "a.b.c")
+}
+
+func checkValuesByPath(t *testing.T, obj JSON, path string, expected ...any) {
+	t.Helper()
+	var res []any
+	ValuesByPath(obj, SplitPath(path), func(v any) bool {
+		res = append(res, v)
+		return true
+	})
+	assert.Equal(t, expected, res)
+}
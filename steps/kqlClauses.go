@@ -0,0 +1,116 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vladimir-rom/gokql"
+)
+
+type kqlClause struct {
+	text       string
+	expression gokql.Expression
+}
+
+// parseKQLClauses splits a filter into its top level 'and' clauses to find out which of them failed.
+// Filters with top level 'or' are kept as a single clause.
+func parseKQLClauses(filter string) []kqlClause {
+	parts := splitKQLTopLevel(filter)
+	if len(parts) < 2 {
+		return nil
+	}
+
+	clauses := make([]kqlClause, 0, len(parts))
+	for _, p := range parts {
+		expr, err := gokql.Parse(p)
+		if err != nil {
+			return nil
+		}
+		clauses = append(clauses, kqlClause{text: p, expression: expr})
+	}
+	return clauses
+}
+
+func kqlFailedClause(clauses []kqlClause, filter string, ev gokql.Evaluator) string {
+	for _, c := range clauses {
+		if matched, err := c.expression.Match(ev); err == nil && !matched {
+			return fmt.Sprintf("does not match %q", c.text)
+		}
+	}
+	return fmt.Sprintf("does not match %q", filter)
+}
+
+func splitKQLTopLevel(filter string) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '{':
+			depth++
+		case c == ')' || c == '}':
+			depth--
+		case depth == 0 && isKQLSpace(c):
+			rest := filter[i+1:]
+			if hasKQLKeyword(rest, "or") {
+				return nil
+			}
+			if hasKQLKeyword(rest, "and") {
+				parts = append(parts, strings.TrimSpace(filter[start:i]))
+				i += len("and")
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, strings.TrimSpace(filter[start:]))
+	return parts
+}
+
+func hasKQLKeyword(s, keyword string) bool {
+	return len(s) > len(keyword) && strings.EqualFold(s[:len(keyword)], keyword) && isKQLSpace(s[len(keyword)])
+}
+
+// normalizeKQLKeywords converts the and, or and not operators written in any case to the lower case the parser expects.
+// Values after ':' and comparison operators and quoted strings are kept as is.
+func normalizeKQLKeywords(filter string) string {
+	res := []byte(filter)
+	var quote byte
+	prev := byte(0)
+	for i := 0; i < len(res); i++ {
+		c := res[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case isKQLSpace(c):
+			continue
+		case (i == 0 || isKQLSpace(res[i-1]) || res[i-1] == '(') && !strings.ContainsRune(":<>=", rune(prev)):
+			for _, keyword := range []string{"and", "or", "not"} {
+				rest := filter[i:]
+				if len(rest) > len(keyword) && strings.EqualFold(rest[:len(keyword)], keyword) &&
+					(isKQLSpace(rest[len(keyword)]) || rest[len(keyword)] == '(') {
+					copy(res[i:], keyword)
+					i += len(keyword) - 1
+					break
+				}
+			}
+		}
+		prev = res[i]
+	}
+	return string(res)
+}
+
+func isKQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,27 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitKQLTopLevel(t *testing.T) {
+	assert.Equal(t, []string{"a:1"}, splitKQLTopLevel("a:1"))
+	assert.Equal(t, []string{"a:1", "b:2", "c>3"}, splitKQLTopLevel("a:1 and b:2 and c>3"))
+	assert.Equal(t, []string{"a:(1 or 2)", "b:{c:1 and d:2}"}, splitKQLTopLevel("a:(1 or 2) and b:{c:1 and d:2}"))
+	assert.Equal(t, []string{"a:'x and y'", "b:2"}, splitKQLTopLevel("a:'x and y' and b:2"))
+	assert.Equal(t, []string{"not a:1", "b:2"}, splitKQLTopLevel("not a:1 and b:2"))
+	assert.Nil(t, splitKQLTopLevel("a:1 and b:2 or c:3"))
+	assert.Equal(t, []string{"android:1"}, splitKQLTopLevel("android:1"))
+	assert.Equal(t, []string{"a:1", "b:2"}, splitKQLTopLevel("a:1 AND b:2"))
+	assert.Nil(t, splitKQLTopLevel("a:1 Or b:2"))
+}
+
+func TestNormalizeKQLKeywords(t *testing.T) {
+	assert.Equal(t, "a:1 and b:2", normalizeKQLKeywords("a:1 AND b:2"))
+	assert.Equal(t, "not a:1 or (not b:2)", normalizeKQLKeywords("NOT a:1 OR (Not b:2)"))
+	assert.Equal(t, "a:(1 or 2)", normalizeKQLKeywords("a:(1 OR 2)"))
+	assert.Equal(t, "msg:'AND OR' and level:OR", normalizeKQLKeywords("msg:'AND OR' And level:OR"))
+	assert.Equal(t, "brand:1 and ORDER:2", normalizeKQLKeywords("brand:1 and ORDER:2"))
+}
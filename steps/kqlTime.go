@@ -0,0 +1,275 @@
+package steps
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kqlTimeField is a time property compared in range clauses of a KQL filter.
+// Its values are converted to unix milliseconds and stored in records under the alias
+// the clauses are rewritten to, so timestamps in any format are compared as times.
+type kqlTimeField struct {
+	name  string
+	alias string
+}
+
+// rewriteKQLTimeRanges replaces range clauses on the time properties like 'ts >= now-15m'
+// or '@timestamp < 2024-03-01T10:00:00Z' with numeric comparisons of their aliases.
+// Date math values are resolved relative to now.
+func rewriteKQLTimeRanges(filter string, timeProps []string, now time.Time) (string, []kqlTimeField, error) {
+	if len(timeProps) == 0 {
+		return filter, nil, nil
+	}
+
+	var sb strings.Builder
+	var fields []kqlTimeField
+	alias := func(name string) string {
+		for _, f := range fields {
+			if f.name == name {
+				return f.alias
+			}
+		}
+		f := kqlTimeField{name: name, alias: fmt.Sprintf("_logex_time%d", len(fields))}
+		fields = append(fields, f)
+		return f.alias
+	}
+
+	depth := 0
+	var quote byte
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case depth == 0 && isKQLNameChar(c) && (i == 0 || isKQLSpace(filter[i-1]) || filter[i-1] == '('):
+			name, op, value, end := scanKQLRange(filter, i)
+			if len(op) == 0 || !slices.Contains(timeProps, name) {
+				break
+			}
+			op, bound, err := resolveKQLTimeBound(op, value, now)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid time in KQL clause %q: %w", filter[i:end], err)
+			}
+			sb.WriteString(alias(name) + " " + op + " " + bound)
+			i = end - 1
+			continue
+		}
+		sb.WriteByte(c)
+	}
+
+	return sb.String(), fields, nil
+}
+
+// scanKQLRange scans a range clause 'name op value' starting at the specified position.
+// The returned operation is empty if there is no range clause.
+func scanKQLRange(filter string, start int) (name, op, value string, end int) {
+	i := start
+	for i < len(filter) && isKQLNameChar(filter[i]) {
+		i++
+	}
+	name = filter[start:i]
+
+	i = skipKQLSpaces(filter, i)
+	for _, o := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(filter[i:], o) {
+			op = o
+			break
+		}
+	}
+	if len(op) == 0 {
+		return name, "", "", i
+	}
+	i = skipKQLSpaces(filter, i+len(op))
+
+	valueStart := i
+	if i < len(filter) && (filter[i] == '\'' || filter[i] == '"') {
+		if idx := strings.IndexByte(filter[i+1:], filter[i]); idx >= 0 {
+			return name, op, filter[i+1 : i+1+idx], i + idx + 2
+		}
+		return name, "", "", i
+	}
+	for i < len(filter) && !isKQLSpace(filter[i]) && filter[i] != ')' {
+		i++
+	}
+	return name, op, filter[valueStart:i], i
+}
+
+// resolveKQLTimeBound converts a time value of a range clause to unix milliseconds.
+// Bounds rounded by date math or given as dates without time include the whole rounded unit
+// for 'gt' and 'lte' clauses, as in Elasticsearch, so the operation may change.
+func resolveKQLTimeBound(op, value string, now time.Time) (string, string, error) {
+	from, to, err := ParseDateMath(value, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	t := from
+	if !to.Equal(from) {
+		switch op {
+		case ">":
+			op, t = ">=", to
+		case "<=":
+			op, t = "<", to
+		}
+	}
+	// quoted as fractions of milliseconds are not valid KQL literals
+	return op, "'" + strconv.FormatFloat(unixMillis(t), 'f', -1, 64) + "'", nil
+}
+
+// ParseDateMath parses a time value in the Elasticsearch date math format: an anchor,
+// which is 'now' or a timestamp followed by '||', and optional operations like '-15m', '+1d' or '/d'.
+// Plain timestamps are accepted as well. Rounded values and dates without time span an interval,
+// its exclusive end is returned as 'to'; for other values 'to' is equal to 'from'.
+// Units are y, M, w, d, h (or H), m and s, times are rounded in UTC.
+func ParseDateMath(value string, now time.Time) (from, to time.Time, err error) {
+	value = strings.TrimSpace(value)
+	var t time.Time
+	var expr string
+	switch {
+	case strings.HasPrefix(value, "now"):
+		t, expr = now.UTC(), value[len("now"):]
+	default:
+		anchor, rest, found := strings.Cut(value, "||")
+		var ok bool
+		if t, ok = ParseTime(anchor); !ok {
+			return time.Time{}, time.Time{}, fmt.Errorf("unknown time format %q", anchor)
+		}
+		t = t.UTC()
+		if !found {
+			if _, err := time.Parse(time.DateOnly, anchor); err == nil {
+				return t, t.AddDate(0, 0, 1), nil
+			}
+			return t, t, nil
+		}
+		expr = rest
+	}
+
+	roundUnit := byte(0)
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '+', '-':
+			sign := 1
+			if expr[0] == '-' {
+				sign = -1
+			}
+			n := 1
+			for n < len(expr) && expr[n] >= '0' && expr[n] <= '9' {
+				n++
+			}
+			if n == 1 || n == len(expr) {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid date math %q", value)
+			}
+			count, err := strconv.Atoi(expr[1:n])
+			if err != nil {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid date math %q: %w", value, err)
+			}
+			if t, err = addTimeUnit(t, expr[n], sign*count); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			expr = expr[n+1:]
+		case '/':
+			if len(expr) < 2 {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid date math %q", value)
+			}
+			roundUnit = expr[1]
+			if t, err = roundTimeUnit(t, roundUnit); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			expr = expr[2:]
+		default:
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date math %q", value)
+		}
+	}
+
+	if roundUnit == 0 {
+		return t, t, nil
+	}
+	to, err = addTimeUnit(t, roundUnit, 1)
+	return t, to, err
+}
+
+func addTimeUnit(t time.Time, unit byte, n int) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown time unit %q", unit)
+}
+
+func roundTimeUnit(t time.Time, unit byte) (time.Time, error) {
+	switch unit {
+	case 'y':
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC), nil
+	case 'M':
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case 'w':
+		// weeks start on Monday
+		days := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, time.UTC), nil
+	case 'd':
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	case 'h', 'H':
+		return t.Truncate(time.Hour), nil
+	case 'm':
+		return t.Truncate(time.Minute), nil
+	case 's':
+		return t.Truncate(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown time unit %q", unit)
+}
+
+// withKQLTimes returns the record with the values of the time properties added as unix milliseconds.
+func withKQLTimes(obj JSON, fields []kqlTimeField) JSON {
+	if len(fields) == 0 {
+		return obj
+	}
+
+	res := make(JSON, len(obj)+len(fields))
+	maps.Copy(res, obj)
+	for _, f := range fields {
+		if t, ok := ParseTime(obj[f.name]); ok {
+			res[f.alias] = unixMillis(t)
+		}
+	}
+	return res
+}
+
+func unixMillis(t time.Time) float64 {
+	return float64(t.UnixMilli()) + float64(t.Nanosecond()%int(time.Millisecond))/1e6
+}
+
+func isKQLNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-' || c == '@' || c == '.'
+}
+
+func skipKQLSpaces(s string, i int) int {
+	for i < len(s) && isKQLSpace(s[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,93 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateMath(t *testing.T) {
+	now := time.Date(2024, 3, 6, 10, 20, 30, 0, time.UTC) // Wednesday
+	checkDateMath(t, "now", now, now, now)
+	checkDateMath(t, "now-15m", now, now.Add(-15*time.Minute), now.Add(-15*time.Minute))
+	checkDateMath(t, "now+1h", now, now.Add(time.Hour), now.Add(time.Hour))
+	checkDateMath(t, "now/d", now,
+		time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC))
+	checkDateMath(t, "now-1d/d", now,
+		time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+	checkDateMath(t, "now/w", now,
+		time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC))
+	checkDateMath(t, "now-1M/M", now,
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	checkDateMath(t, "2024-03-01T10:00:00Z||+1h", now,
+		time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC))
+	checkDateMath(t, "2024-03-01T12:00:00+02:00", now,
+		time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	checkDateMath(t, "2024-03-01", now,
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+
+	for _, invalid := range []string{"yesterday", "now-15", "now-m", "now/x", "now+1q", "now-1d/"} {
+		_, _, err := ParseDateMath(invalid, now)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func checkDateMath(t *testing.T, value string, now, expectedFrom, expectedTo time.Time) {
+	t.Helper()
+	from, to, err := ParseDateMath(value, now)
+	require.NoError(t, err)
+	assert.True(t, expectedFrom.Equal(from), "%s: expected from %v, got %v", value, expectedFrom, from)
+	assert.True(t, expectedTo.Equal(to), "%s: expected to %v, got %v", value, expectedTo, to)
+}
+
+func TestRewriteKQLTimeRanges(t *testing.T) {
+	now := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+	nowMs := "'1709719200000'"
+	dayMs := "'1709683200000'"
+	nextDayMs := "'1709769600000'"
+
+	check := func(filter, expected string, expectedFields ...kqlTimeField) {
+		t.Helper()
+		res, fields, err := rewriteKQLTimeRanges(filter, DefaultTimeFields, now)
+		require.NoError(t, err)
+		assert.Equal(t, expected, res)
+		assert.Equal(t, expectedFields, fields)
+	}
+	ts := kqlTimeField{name: "ts", alias: "_logex_time0"}
+
+	check("level:error", "level:error")
+	check("ts >= now", "_logex_time0 >= "+nowMs, ts)
+	check("ts>now/d and ts<=now/d", "_logex_time0 >= "+nextDayMs+" and _logex_time0 < "+nextDayMs, ts)
+	check("(ts < now/d or level:error)", "(_logex_time0 < "+dayMs+" or level:error)", ts)
+	check("@timestamp >= '2024-03-06T10:00:00Z' and ts < 2024-03-06T10:00:00Z",
+		"_logex_time0 >= "+nowMs+" and _logex_time1 < "+nowMs,
+		kqlTimeField{name: "@timestamp", alias: "_logex_time0"}, kqlTimeField{name: "ts", alias: "_logex_time1"})
+	check("msg:'ts > now' and duration > 5", "msg:'ts > now' and duration > 5")
+	check("ctx:{ts > 5}", "ctx:{ts > 5}")
+	check("ts:now", "ts:now")
+
+	_, _, err := rewriteKQLTimeRanges("ts > yesterday", DefaultTimeFields, now)
+	assert.ErrorContains(t, err, `"ts > yesterday"`)
+}
+
+func TestWithKQLTimes(t *testing.T) {
+	fields := []kqlTimeField{{name: "ts", alias: "_logex_time0"}}
+	expected := float64(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC).UnixMilli())
+
+	for _, ts := range []any{"2024-03-01T10:00:00Z", "2024-03-01 12:00:00+02:00", float64(1709287200), float64(1709287200123) - 123} {
+		res := withKQLTimes(JSON{"ts": ts}, fields)
+		assert.Equal(t, expected, res["_logex_time0"], ts)
+	}
+
+	obj := JSON{"ts": "unknown"}
+	assert.Equal(t, JSON{"ts": "unknown"}, withKQLTimes(obj, fields))
+}
@@ -61,3 +61,16 @@ func parseMetaConfig(metaCfg string) (*metaConfig, error) {
 
 	return result, nil
 }
+
+func FilterByRecNum(opts pipeline.PipelineOptions, recNum int, fileName string) pipeline.Step[JSON, JSON] {
+	if recNum < 0 {
+		return Noop[JSON]()
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.RecNum != recNum || (len(fileName) > 0 && obj.Metadata.FileName != fileName) {
+			return true
+		}
+		return yield(obj, nil)
+	})
+}
@@ -0,0 +1,203 @@
+package steps
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/vladimir-rom/gokql"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// PairConfig describes matching of start and end records of operations.
+type PairConfig struct {
+	// Fields are property paths forming the key of an operation
+	Fields []string
+	// Start and End are KQL conditions of the start and end records
+	Start string
+	End   string
+	// TimeProps are property names of the record time
+	TimeProps []string
+	// Timeout is the time after which a start without an end record is reported as lost instead of running.
+	// Zero means all unmatched starts are running.
+	Timeout time.Duration
+	// Warn is called at the end of the stream with the numbers of unmatched records
+	Warn func(msg string)
+}
+
+type pairStart struct {
+	item pipeline.Item[JSON]
+	seq  int
+}
+
+// Pair matches start and end records with the same key. The start record is removed and the end record is replaced
+// by the start record with the 'end' field containing the end record, 'start_time', 'end_time' and 'duration_ms' fields.
+// Starts are matched in the order of their arrival. Starts without end records are output at the end of the stream
+// with the 'unmatched' field: "running" or "lost" if they are older than the timeout, and the 'age_ms' field.
+// Removed start records are output just before their end records, so unmatched starts are output once.
+// Start and end records without some of the key properties are not paired.
+func Pair(opts pipeline.PipelineOptions, cfg PairConfig) (pipeline.Step[JSON, JSON], error) {
+	if len(cfg.Fields) == 0 {
+		return Noop[JSON](), nil
+	}
+	if len(cfg.Start) == 0 || len(cfg.End) == 0 {
+		return nil, fmt.Errorf("pairing by %v requires start and end conditions", cfg.Fields)
+	}
+	isStart, err := compileKQL(cfg.Start, cfg.TimeProps)
+	if err != nil {
+		return nil, fmt.Errorf("pair start condition: %w", err)
+	}
+	isEnd, err := compileKQL(cfg.End, cfg.TimeProps)
+	if err != nil {
+		return nil, fmt.Errorf("pair end condition: %w", err)
+	}
+
+	paths := make([][]string, len(cfg.Fields))
+	for i, f := range cfg.Fields {
+		paths[i] = SplitPath(f)
+	}
+
+	open := make(map[string][]pairStart)
+	seq := 0
+	unmatchedEnds := 0
+	unkeyed := 0
+	var lastTime time.Time
+
+	return pipeline.NewStepWithFin(
+		opts,
+		func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+			if obj.Metadata.Removed {
+				return yield(obj, nil)
+			}
+			if t, ok := RecordTime(obj.Value, cfg.TimeProps); ok && t.After(lastTime) {
+				lastTime = t
+			}
+
+			end, err := isEnd(obj.Value)
+			if err != nil {
+				return yield(obj.WithValue(nil), err)
+			}
+			start := false
+			if !end {
+				if start, err = isStart(obj.Value); err != nil {
+					return yield(obj.WithValue(nil), err)
+				}
+			}
+			if (start || end) && !hasAllPaths(obj.Value, paths) {
+				unkeyed++
+				return yield(obj, nil)
+			}
+
+			if end {
+				key := distinctKey(obj.Value, paths)
+				starts := open[key]
+				if len(starts) == 0 {
+					unmatchedEnds++
+					return yield(obj, nil)
+				}
+				first := starts[0]
+				if len(starts) == 1 {
+					delete(open, key)
+				} else {
+					open[key] = starts[1:]
+				}
+				removed := first.item
+				removed.Metadata.Remove(opts, func() string {
+					return "start of a pair with " + describeKey(cfg.Fields, removed.Value, paths)
+				})
+				if !yield(removed, nil) {
+					return false
+				}
+				return yield(obj.WithValue(pairRecord(first.item.Value, obj.Value, cfg.TimeProps)), nil)
+			}
+
+			if !start {
+				return yield(obj, nil)
+			}
+			seq++
+			key := distinctKey(obj.Value, paths)
+			open[key] = append(open[key], pairStart{item: obj, seq: seq})
+			return true
+		},
+		func(yield pipeline.Yield[JSON]) {
+			var starts []pairStart
+			for _, s := range open {
+				starts = append(starts, s...)
+			}
+			slices.SortFunc(starts, func(a, b pairStart) int { return a.seq - b.seq })
+
+			lost := 0
+			for _, s := range starts {
+				rec := maps.Clone(s.item.Value)
+				rec["unmatched"] = "running"
+				if t, ok := RecordTime(rec, cfg.TimeProps); ok && !lastTime.IsZero() {
+					age := lastTime.Sub(t)
+					rec["age_ms"] = float64(age) / float64(time.Millisecond)
+					if cfg.Timeout > 0 && age > cfg.Timeout {
+						rec["unmatched"] = "lost"
+						lost++
+					}
+				}
+				if !yield(s.item.WithValue(rec), nil) {
+					return
+				}
+			}
+
+			if cfg.Warn != nil && len(starts) > 0 {
+				cfg.Warn(fmt.Sprintf("pair: %d start records without end records (%d running, %d lost)", len(starts), len(starts)-lost, lost))
+			}
+			if cfg.Warn != nil && unmatchedEnds > 0 {
+				cfg.Warn(fmt.Sprintf("pair: %d end records without start records", unmatchedEnds))
+			}
+			if cfg.Warn != nil && unkeyed > 0 {
+				cfg.Warn(fmt.Sprintf("pair: %d start or end records without %v are not paired", unkeyed, cfg.Fields))
+			}
+		}), nil
+}
+
+func hasAllPaths(obj JSON, paths [][]string) bool {
+	for _, path := range paths {
+		found := false
+		ValuesByPath(obj, path, func(any) bool {
+			found = true
+			return false
+		})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func pairRecord(start, end JSON, timeProps []string) JSON {
+	rec := maps.Clone(start)
+	rec["end"] = end
+	rec["start_time"] = recordTimeValue(start, timeProps)
+	rec["end_time"] = recordTimeValue(end, timeProps)
+	startTime, ok1 := RecordTime(start, timeProps)
+	endTime, ok2 := RecordTime(end, timeProps)
+	if ok1 && ok2 {
+		rec["duration_ms"] = float64(endTime.Sub(startTime)) / float64(time.Millisecond)
+	}
+	return rec
+}
+
+// compileKQL returns a function matching records with the KQL expression.
+func compileKQL(filter string, timeProps []string) (func(obj JSON) (bool, error), error) {
+	rewritten, timeFields, err := rewriteKQLTimeRanges(normalizeKQLKeywords(filter), timeProps, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	expression, err := gokql.Parse(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("filter parsing error: %w", err)
+	}
+	return func(obj JSON) (bool, error) {
+		ev, err := gokql.NewMapEvaluator(map[string]any(withKQLTimes(obj, timeFields)))
+		if err != nil {
+			return false, err
+		}
+		return expression.Match(ev)
+	}, nil
+}
@@ -0,0 +1,120 @@
+package steps
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+var pairRecords = []JSON{
+	{"ts": "2024-03-01T10:00:00Z", "msg": "job started", "job": JSON{"id": float64(1)}},
+	{"ts": "2024-03-01T10:00:01Z", "msg": "job started", "job": JSON{"id": float64(2)}},
+	{"ts": "2024-03-01T10:00:02Z", "msg": "progress", "job": JSON{"id": float64(1)}},
+	{"ts": "2024-03-01T10:00:03.5Z", "msg": "job finished", "job": JSON{"id": float64(1)}},
+	{"ts": "2024-03-01T10:00:04Z", "msg": "job finished", "job": JSON{"id": float64(7)}},
+	{"ts": "2024-03-01T10:00:05Z", "msg": "job started", "job": JSON{"id": float64(3)}},
+	{"ts": "2024-03-01T10:00:10Z", "msg": "progress", "job": JSON{"id": float64(3)}},
+}
+
+func TestPair(t *testing.T) {
+	var warnings []string
+	step, err := Pair(pipeline.PipelineOptions{}, PairConfig{
+		Fields:    []string{"job.id"},
+		Start:     `msg:"job started"`,
+		End:       `msg:"job finished"`,
+		TimeProps: []string{"ts"},
+		Timeout:   6 * time.Second,
+		Warn:      func(msg string) { warnings = append(warnings, msg) },
+	})
+	require.NoError(t, err)
+
+	in := cloneRecords(pairRecords)
+	res := sampled(step(sliceToSeq(in)))
+	assert.Equal(t, []JSON{
+		in[2],
+		{
+			"ts": "2024-03-01T10:00:00Z", "msg": "job started", "job": JSON{"id": float64(1)},
+			"end":         in[3],
+			"start_time":  "2024-03-01T10:00:00Z",
+			"end_time":    "2024-03-01T10:00:03.5Z",
+			"duration_ms": float64(3500),
+		},
+		in[4],
+		in[6],
+		{"ts": "2024-03-01T10:00:01Z", "msg": "job started", "job": JSON{"id": float64(2)}, "unmatched": "lost", "age_ms": float64(9000)},
+		{"ts": "2024-03-01T10:00:05Z", "msg": "job started", "job": JSON{"id": float64(3)}, "unmatched": "running", "age_ms": float64(5000)},
+	}, res)
+	assert.Equal(t, []string{
+		"pair: 2 start records without end records (1 running, 1 lost)",
+		"pair: 1 end records without start records",
+	}, warnings)
+}
+
+func TestPairKeepRemoved(t *testing.T) {
+	step, err := Pair(pipeline.PipelineOptions{KeepRemoved: true}.Named("pair"), PairConfig{
+		Fields: []string{"job.id"},
+		Start:  `msg:"job started"`,
+		End:    `msg:"job finished"`,
+	})
+	require.NoError(t, err)
+
+	// the matched start is output as removed before its end record, unmatched starts are output once
+	var msgs, reasons []string
+	for item, err := range step(sliceToSeq(cloneRecords(pairRecords))) {
+		require.NoError(t, err)
+		msgs = append(msgs, fmt.Sprintf("%v %v", item.Value["msg"], item.Value["job"].(JSON)["id"]))
+		reasons = append(reasons, item.Metadata.RemovedBy)
+	}
+	assert.Equal(t, []string{
+		"progress 1", "job started 1", "job started 1", "job finished 7", "progress 3", "job started 2", "job started 3",
+	}, msgs)
+	assert.Equal(t, []string{"", "pair: start of a pair with job.id:1", "", "", "", "", ""}, reasons)
+}
+
+func TestPairMissingKey(t *testing.T) {
+	var warnings []string
+	step, err := Pair(pipeline.PipelineOptions{}, PairConfig{
+		Fields: []string{"job.id"},
+		Start:  `msg:"job started"`,
+		End:    `msg:"job finished"`,
+		Warn:   func(msg string) { warnings = append(warnings, msg) },
+	})
+	require.NoError(t, err)
+
+	in := []JSON{
+		{"ts": "2024-03-01T10:00:00Z", "msg": "job started"},
+		{"ts": "2024-03-01T10:00:01Z", "msg": "job finished"},
+	}
+	assert.Equal(t, in, sampled(step(sliceToSeq(in))))
+	assert.Equal(t, []string{"pair: 2 start or end records without [job.id] are not paired"}, warnings)
+}
+
+func TestPairRepeatedKey(t *testing.T) {
+	step, err := Pair(pipeline.PipelineOptions{}, PairConfig{
+		Fields:    []string{"job"},
+		Start:     "event:start",
+		End:       "event:end",
+		TimeProps: []string{"ts"},
+	})
+	require.NoError(t, err)
+
+	res := sampled(step(sliceToSeq([]JSON{
+		{"ts": float64(100), "event": "start", "job": "a"},
+		{"ts": float64(101), "event": "start", "job": "a"},
+		{"ts": float64(103), "event": "end", "job": "a"},
+		{"ts": float64(107), "event": "end", "job": "a"},
+	})))
+	// starts are matched in the order of their arrival
+	require.Len(t, res, 2)
+	assert.Equal(t, float64(3000), res[0]["duration_ms"])
+	assert.Equal(t, float64(6000), res[1]["duration_ms"])
+}
+
+func TestPairConditionsRequired(t *testing.T) {
+	_, err := Pair(pipeline.PipelineOptions{}, PairConfig{Fields: []string{"job"}, Start: "event:start"})
+	assert.Error(t, err)
+}
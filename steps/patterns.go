@@ -0,0 +1,49 @@
+package steps
+
+import (
+	"fmt"
+
+	"github.com/vladimir-rom/logex/patterns"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// MessageOf returns the value of the message property as a string.
+func MessageOf(obj JSON, path []string) (string, bool) {
+	var msg string
+	found := false
+	ValuesByPath(obj, path, func(v any) bool {
+		if s, ok := v.(string); ok {
+			msg = s
+		} else {
+			msg = fmt.Sprint(v)
+		}
+		found = true
+		return false
+	})
+	return msg, found
+}
+
+// IncludePatterns keeps records whose message property matches any of the templates.
+func IncludePatterns(opts pipeline.PipelineOptions, field string, templates []*patterns.Cluster) pipeline.Step[JSON, JSON] {
+	if len(templates) == 0 {
+		return Noop[JSON]()
+	}
+
+	path := SplitPath(field)
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		msg, _ := MessageOf(obj.Value, path)
+		tokens := patterns.Tokenize(msg)
+		for _, t := range templates {
+			if t.MatchesTokens(tokens) {
+				return yield(obj, nil)
+			}
+		}
+
+		obj.Metadata.Remove(opts, func() string { return fmt.Sprintf("%s does not match the patterns", field) })
+		return yield(obj, nil)
+	})
+}
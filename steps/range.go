@@ -0,0 +1,96 @@
+package steps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// RecRange is an inclusive range of record numbers. To is -1 for an open range.
+// An empty FileName means the range applies to all files.
+type RecRange struct {
+	FileName string
+	From     int
+	To       int
+}
+
+func (r RecRange) contains(recNum int) bool {
+	return recNum >= r.From && (r.To < 0 || recNum <= r.To)
+}
+
+// ParseRange parses a range specification: 'FROM-TO', 'FROM-', '-TO', 'N' or 'FILE:FROM-TO'.
+func ParseRange(spec string) (RecRange, error) {
+	spec = strings.TrimSpace(spec)
+	var fileName string
+	if idx := strings.LastIndex(spec, ":"); idx >= 0 {
+		fileName, spec = spec[:idx], spec[idx+1:]
+		if len(fileName) == 0 {
+			return RecRange{}, fmt.Errorf("invalid range %q: empty file name", spec)
+		}
+	}
+
+	parseNum := func(s string, dflt int) (int, error) {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			return dflt, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid range %q, expected 'FROM-TO'", spec)
+		}
+		return n, nil
+	}
+
+	fromStr, toStr, found := strings.Cut(spec, "-")
+	if !found {
+		toStr = fromStr
+	}
+	if len(strings.TrimSpace(fromStr)) == 0 && len(strings.TrimSpace(toStr)) == 0 {
+		return RecRange{}, fmt.Errorf("invalid range %q, expected 'FROM-TO'", spec)
+	}
+
+	from, err := parseNum(fromStr, 0)
+	if err != nil {
+		return RecRange{}, err
+	}
+	to, err := parseNum(toStr, -1)
+	if err != nil {
+		return RecRange{}, err
+	}
+	if to >= 0 && to < from {
+		return RecRange{}, fmt.Errorf("invalid range %q: end is less than start", spec)
+	}
+
+	return RecRange{FileName: fileName, From: from, To: to}, nil
+}
+
+// FilterByRange skips lines whose record numbers are outside the ranges.
+// It works before JSON parsing and stops reading a file once all its ranges are passed.
+// Files not mentioned in any range are not restricted unless there are ranges without a file name.
+func FilterByRange(opts pipeline.PipelineOptions, ranges []RecRange) pipeline.Step[string, string] {
+	if len(ranges) == 0 {
+		return Noop[string]()
+	}
+
+	return pipeline.NewStep(opts, func(line pipeline.Item[string], yield pipeline.Yield[string]) bool {
+		restricted, matched, passed := false, false, true
+		for _, r := range ranges {
+			if len(r.FileName) > 0 && r.FileName != line.Metadata.FileName {
+				continue
+			}
+			restricted = true
+			matched = matched || r.contains(line.Metadata.RecNum)
+			passed = passed && r.To >= 0 && line.Metadata.RecNum >= r.To
+		}
+
+		if !restricted {
+			return yield(line, nil)
+		}
+		if matched && !yield(line, nil) {
+			return false
+		}
+		return !passed
+	})
+}
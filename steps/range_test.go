@@ -0,0 +1,52 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func TestParseRange(t *testing.T) {
+	checkRangeParsing(t, "100-200", RecRange{From: 100, To: 200})
+	checkRangeParsing(t, "5000-", RecRange{From: 5000, To: -1})
+	checkRangeParsing(t, "-10", RecRange{From: 0, To: 10})
+	checkRangeParsing(t, "7", RecRange{From: 7, To: 7})
+	checkRangeParsing(t, "worker.log:100-200", RecRange{FileName: "worker.log", From: 100, To: 200})
+	checkRangeParsing(t, `c:\logs\worker.log:100-`, RecRange{FileName: `c:\logs\worker.log`, From: 100, To: -1})
+
+	for _, spec := range []string{"", "-", "a-b", "200-100", ":1-2", "1-2-3"} {
+		_, err := ParseRange(spec)
+		assert.Errorf(t, err, "spec: %q", spec)
+	}
+}
+
+func checkRangeParsing(t *testing.T, spec string, expected RecRange) {
+	t.Helper()
+	r, err := ParseRange(spec)
+	require.NoError(t, err)
+	assert.Equal(t, expected, r)
+}
+
+func TestFilterByRange(t *testing.T) {
+	read := 0
+	lines := func(yield pipeline.Yield[string]) {
+		for i := range 100 {
+			read++
+			item := pipeline.Item[string]{Value: "{}", Metadata: pipeline.Metadata{RecNum: i, FileName: "f"}}
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+
+	var recNums []int
+	filter := FilterByRange(pipeline.PipelineOptions{}, []RecRange{{From: 3, To: 5}, {From: 10, To: 11}, {FileName: "g", From: 0, To: 50}})
+	for line, _ := range filter(lines) {
+		recNums = append(recNums, line.Metadata.RecNum)
+	}
+
+	assert.Equal(t, []int{3, 4, 5, 10, 11}, recNums)
+	assert.Equal(t, 12, read)
+}
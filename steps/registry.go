@@ -0,0 +1,249 @@
+package steps
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// StringSteps contains steps processing raw lines before they are parsed as JSON.
+var StringSteps = pipeline.NewRegistry[string, string]()
+
+// JSONSteps contains steps processing parsed JSON records.
+var JSONSteps = pipeline.NewRegistry[JSON, JSON]()
+
+func init() {
+	StringSteps.Register(pipeline.StepDef[string, string]{
+		Name: "include",
+		Help: "Include only records containing any of the specified substrings",
+		Params: []pipeline.ParamDef{
+			{Name: "substrings", Type: pipeline.ParamStrings, Help: "substrings to search for"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[string, string], error) {
+			return IncludeSubstringsAny(opts, p.Strings("substrings")), nil
+		},
+	})
+
+	StringSteps.Register(pipeline.StepDef[string, string]{
+		Name: "exclude",
+		Help: "Exclude records containing any of the specified substrings",
+		Params: []pipeline.ParamDef{
+			{Name: "substrings", Type: pipeline.ParamStrings, Help: "substrings to search for"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[string, string], error) {
+			return ExcludeSubstringsAny(opts, p.Strings("substrings")), nil
+		},
+	})
+
+	StringSteps.Register(pipeline.StepDef[string, string]{
+		Name: "include-regexp",
+		Help: "Include only records that match any of the specified regular expressions",
+		Params: []pipeline.ParamDef{
+			{Name: "regexps", Type: pipeline.ParamStrings, Help: "regular expressions"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[string, string], error) {
+			return IncludeRegexp(opts, p.Strings("regexps"))
+		},
+	})
+
+	StringSteps.Register(pipeline.StepDef[string, string]{
+		Name: "exclude-regexp",
+		Help: "Exclude records that match any of the specified regular expressions",
+		Params: []pipeline.ParamDef{
+			{Name: "regexps", Type: pipeline.ParamStrings, Help: "regular expressions"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[string, string], error) {
+			return ExcludeRegexp(opts, p.Strings("regexps"))
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "select",
+		Help: "Output only the specified properties",
+		Params: []pipeline.ParamDef{
+			{Name: "props", Type: pipeline.ParamStrings, Help: "property names to output"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return Select(opts, p.Strings("props")), nil
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "hide",
+		Help: "Remove the specified properties",
+		Params: []pipeline.ParamDef{
+			{Name: "props", Type: pipeline.ParamStrings, Help: "property names to hide"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return Hide(opts, p.Strings("props")), nil
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "expand",
+		Help: "Parse string properties as JSON values",
+		Params: []pipeline.ParamDef{
+			{Name: "props", Type: pipeline.ParamStrings, Help: "property names to expand"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return Expand(opts, p.Strings("props")), nil
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "where",
+		Help: "Include only records whose field matches the condition",
+		Params: []pipeline.ParamDef{
+			{Name: "condition", Type: pipeline.ParamString, Help: "condition, for example 'msg~timeout.*db'"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return Where(opts, nonEmpty(p.String("condition")))
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "where-not",
+		Help: "Exclude records whose field matches the condition",
+		Params: []pipeline.ParamDef{
+			{Name: "condition", Type: pipeline.ParamString, Help: "condition, for example 'user=bot'"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return WhereNot(opts, nonEmpty(p.String("condition")))
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name: "kql",
+		Help: "Filter records by a Kibana Query Language expression",
+		Params: []pipeline.ParamDef{
+			{Name: "filter", Type: pipeline.ParamString, Help: "KQL expression"},
+			{Name: "time-fields", Type: pipeline.ParamStrings, Default: DefaultTimeFields, Help: "properties compared as timestamps in range clauses"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			return FilterByKQL(opts, p.String("filter"), p.Strings("time-fields"))
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name:     "distinct-by",
+		Stateful: true,
+		Help:     "Return distinct records based on the specified properties",
+		Params: []pipeline.ParamDef{
+			{Name: "prop", Type: pipeline.ParamStrings, Help: "property names forming the key"},
+			{Name: "keep", Type: pipeline.ParamString, Default: "first", Help: "record to keep: first or last"},
+			{Name: "count", Type: pipeline.ParamBool, Help: "add count, first_seen and last_seen fields"},
+			{Name: "count-prefix", Type: pipeline.ParamString, Help: "prefix of the count field names"},
+			{Name: "limit", Type: pipeline.ParamInt, Help: "maximum number of keys to remember"},
+			{Name: "time-fields", Type: pipeline.ParamStrings, Default: DefaultTimeFields, Help: "properties containing record timestamps"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			keep := p.String("keep")
+			if keep != "first" && keep != "last" {
+				return nil, fmt.Errorf("invalid keep value %q, expected first or last", keep)
+			}
+			return DistinctBy(opts, DistinctConfig{
+				Fields:      p.Strings("prop"),
+				KeepLast:    keep == "last",
+				Count:       p.Bool("count"),
+				CountPrefix: p.String("count-prefix"),
+				TimeProps:   p.Strings("time-fields"),
+				MaxKeys:     p.Int("limit"),
+				Warn:        opts.Warn,
+			}), nil
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name:     "pair",
+		Stateful: true,
+		Help:     "Match start and end records with the same key and compute the duration",
+		Params: []pipeline.ParamDef{
+			{Name: "by", Type: pipeline.ParamStrings, Help: "property names forming the key"},
+			{Name: "start", Type: pipeline.ParamString, Help: "KQL condition of the start records"},
+			{Name: "end", Type: pipeline.ParamString, Help: "KQL condition of the end records"},
+			{Name: "timeout", Type: pipeline.ParamString, Help: "age after which unmatched starts are reported as lost, for example 1h"},
+			{Name: "time-fields", Type: pipeline.ParamStrings, Default: DefaultTimeFields, Help: "properties containing record timestamps"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			var timeout time.Duration
+			if t := p.String("timeout"); len(t) > 0 {
+				var err error
+				if timeout, err = time.ParseDuration(t); err != nil {
+					return nil, fmt.Errorf("invalid pair timeout: %w", err)
+				}
+			}
+			return Pair(opts, PairConfig{
+				Fields:    p.Strings("by"),
+				Start:     p.String("start"),
+				End:       p.String("end"),
+				TimeProps: p.Strings("time-fields"),
+				Timeout:   timeout,
+				Warn:      opts.Warn,
+			})
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name:     "sample",
+		Stateful: true,
+		Help:     "Keep a random fraction, every Nth record or records with a deterministic subset of key values",
+		Params: []pipeline.ParamDef{
+			{Name: "rate", Type: pipeline.ParamString, Help: "fraction of records to keep: 0.01 or 1%"},
+			{Name: "every", Type: pipeline.ParamInt, Help: "keep every Nth record"},
+			{Name: "by", Type: pipeline.ParamString, Help: "property whose values are hashed, records with the same value are kept or removed together"},
+			{Name: "seed", Type: pipeline.ParamInt, Help: "seed of the random sampling, makes the result reproducible"},
+			{Name: "field", Type: pipeline.ParamString, Help: "property name to add the sampling rate to"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			cfg := SampleConfig{
+				Every: p.Int("every"),
+				By:    p.String("by"),
+				Seed:  uint64(p.Int("seed")),
+				Field: p.String("field"),
+			}
+			if rate := p.String("rate"); len(rate) > 0 {
+				var err error
+				if cfg.Rate, err = ParseRate(rate); err != nil {
+					return nil, err
+				}
+			}
+			if cfg.Rate == 0 && cfg.Every == 0 {
+				return nil, fmt.Errorf("sampling requires rate or every")
+			}
+			return Sample(opts, cfg)
+		},
+	})
+
+	JSONSteps.Register(pipeline.StepDef[JSON, JSON]{
+		Name:     "collapse",
+		Stateful: true,
+		Help:     "Collapse repeated records into one with the number of repeats and first/last timestamps",
+		Params: []pipeline.ParamDef{
+			{Name: "by", Type: pipeline.ParamStrings, Default: []string{"level", "msg"}, Help: "properties identifying repeated records"},
+			{Name: "window", Type: pipeline.ParamString, Help: "suppress non-consecutive repeats within the time window, for example 10s"},
+			{Name: "time-fields", Type: pipeline.ParamStrings, Default: DefaultTimeFields, Help: "properties containing record timestamps"},
+		},
+		Factory: func(opts pipeline.PipelineOptions, p pipeline.Params) (pipeline.Step[JSON, JSON], error) {
+			var window time.Duration
+			if w := p.String("window"); len(w) > 0 {
+				var err error
+				if window, err = time.ParseDuration(w); err != nil {
+					return nil, fmt.Errorf("invalid collapse window: %w", err)
+				}
+			}
+			return Collapse(opts, CollapseConfig{
+				Fields:    p.Strings("by"),
+				Window:    window,
+				TimeProps: p.Strings("time-fields"),
+			}), nil
+		},
+	})
+}
+
+func nonEmpty(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return []string{s}
+}
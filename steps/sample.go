@@ -0,0 +1,115 @@
+package steps
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// SampleConfig describes the sampling. Exactly one of Rate and Every must be set.
+type SampleConfig struct {
+	// Rate is a fraction of records to keep, between 0 and 1
+	Rate float64
+	// Every keeps every Nth record
+	Every int
+	// By is a property path, records with the same value are either all kept or all removed
+	By string
+	// Seed makes random sampling reproducible when not zero
+	Seed uint64
+	// Field is a property name to store the sampling rate to
+	Field string
+}
+
+// ParseRate parses a sampling rate specified as a fraction (0.01) or as a percentage (1%).
+// A zero rate is an error, it would remove all records.
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	divisor := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		divisor = 100
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || rate < 0 || rate/divisor > 1 {
+		return 0, fmt.Errorf("invalid sampling rate %q, expected a fraction like 0.01 or a percentage like 1%%", s)
+	}
+	if rate == 0 {
+		return 0, fmt.Errorf("invalid sampling rate %q, a zero rate keeps no records", s)
+	}
+	return rate / divisor, nil
+}
+
+// Sample keeps a random fraction, every Nth record or a hash-based subset of records.
+// The zero configuration means no sampling.
+func Sample(opts pipeline.PipelineOptions, cfg SampleConfig) (pipeline.Step[JSON, JSON], error) {
+	if cfg.Rate == 0 && cfg.Every == 0 {
+		return Noop[JSON](), nil
+	}
+	if cfg.Rate != 0 && cfg.Every != 0 {
+		return nil, fmt.Errorf("sampling rate and every can not be used together")
+	}
+	if cfg.Every < 0 {
+		return nil, fmt.Errorf("invalid sampling every value: %d", cfg.Every)
+	}
+
+	rate := cfg.Rate
+	if cfg.Every > 0 {
+		rate = 1 / float64(cfg.Every)
+	}
+
+	var keep func(obj JSON) bool
+	switch {
+	case len(cfg.By) > 0:
+		// a key is kept when its hash is in the first rate part of the hash range,
+		// so the same keys are selected in different runs and files
+		path := SplitPath(cfg.By)
+		threshold := rate * (1 << 64)
+		keep = func(obj JSON) bool {
+			h := fnv.New64a()
+			ValuesByPath(obj, path, func(v any) bool {
+				fmt.Fprint(h, v)
+				return false
+			})
+			return rate >= 1 || float64(mix(h.Sum64())) < threshold
+		}
+	case cfg.Every > 0:
+		n := 0
+		keep = func(JSON) bool {
+			n++
+			return (n-1)%cfg.Every == 0
+		}
+	default:
+		random := rand.Float64
+		if cfg.Seed != 0 {
+			random = rand.New(rand.NewPCG(cfg.Seed, 0)).Float64
+		}
+		keep = func(JSON) bool { return random() < rate }
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		if !keep(obj.Value) {
+			obj.Metadata.Remove(opts, func() string { return "not sampled" })
+		} else if len(cfg.Field) > 0 {
+			obj.Value[cfg.Field] = rate
+		}
+		return yield(obj, nil)
+	}), nil
+}
+
+// mix spreads the bits of FNV hashes of short keys over the whole range (splitmix64 finalizer).
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,65 @@
+package steps
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+func TestParseRate(t *testing.T) {
+	for spec, expected := range map[string]float64{"0.01": 0.01, "1%": 0.01, "100%": 1, "1": 1, " 50 % ": 0.5} {
+		rate, err := ParseRate(spec)
+		require.NoError(t, err, spec)
+		assert.InDelta(t, expected, rate, 1e-9, spec)
+	}
+
+	for _, spec := range []string{"", "abc", "2", "101%", "-1%", "0", "0%", "0.0"} {
+		_, err := ParseRate(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSampleByKey(t *testing.T) {
+	records := make([]JSON, 0, 1000)
+	for i := range 1000 {
+		records = append(records, JSON{"trace": fmt.Sprint(i % 100), "n": i})
+	}
+
+	sample := func(rate float64) map[string]int {
+		step, err := Sample(pipeline.PipelineOptions{}, SampleConfig{Rate: rate, By: "trace"})
+		require.NoError(t, err)
+		counts := make(map[string]int)
+		for _, r := range sampled(step(sliceToSeq(records))) {
+			counts[r["trace"].(string)]++
+		}
+		return counts
+	}
+
+	small, large := sample(0.2), sample(0.5)
+	assert.NotEmpty(t, small)
+	assert.Less(t, len(small), len(large))
+	for trace, count := range small {
+		assert.Equal(t, 10, count, "all records of a trace are kept")
+		assert.Contains(t, large, trace, "keys sampled with a lower rate are sampled with a higher rate")
+	}
+}
+
+func TestSampleRandomSeed(t *testing.T) {
+	records := make([]JSON, 0, 1000)
+	for i := range 1000 {
+		records = append(records, JSON{"n": i})
+	}
+
+	sample := func() []JSON {
+		step, err := Sample(pipeline.PipelineOptions{}, SampleConfig{Rate: 0.1, Seed: 42})
+		require.NoError(t, err)
+		return sampled(step(sliceToSeq(records)))
+	}
+
+	first := sample()
+	assert.InDelta(t, 100, len(first), 50)
+	assert.Equal(t, first, sample())
+}
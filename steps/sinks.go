@@ -0,0 +1,45 @@
+package steps
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+type Sink struct {
+	Writer     io.Writer
+	Format     pipeline.Step[JSON, string]
+	ShowErrors bool
+}
+
+func WriteSinks(sinks []Sink, records pipeline.Seq[JSON]) error {
+	if len(sinks) == 1 {
+		return WriteLines(sinks[0].Writer, sinks[0].ShowErrors, sinks[0].Format(records))
+	}
+
+	for rec, err := range records {
+		for _, s := range sinks {
+			if err != nil {
+				if s.ShowErrors {
+					if _, err := fmt.Fprintln(s.Writer, err); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if err := WriteLines(s.Writer, s.ShowErrors, s.Format(single(rec))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func single[T any](item pipeline.Item[T]) pipeline.Seq[T] {
+	return func(yield pipeline.Yield[T]) {
+		yield(item, nil)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"strings"
 	"time"
@@ -44,7 +45,7 @@ func ExcludeSubstringsAny(opts pipeline.PipelineOptions, substrings []string) pi
 
 		for _, s := range substrings {
 			if strcase.Contains(line.Value, s) {
-				line.Metadata.Removed = true
+				line.Metadata.Remove(opts, func() string { return fmt.Sprintf("contains %q", s) })
 				break
 			}
 		}
@@ -68,12 +69,12 @@ func IncludeSubstringsAny(opts pipeline.PipelineOptions, substrings []string) pi
 			}
 
 		}
-		line.Metadata.Removed = true
+		line.Metadata.Remove(opts, func() string { return fmt.Sprintf("does not contain any of %q", substrings) })
 		return yield(line, nil)
 	})
 }
 
-func StrToJson(opts pipeline.PipelineOptions, durationMs []string) pipeline.Step[string, JSON] {
+func StrToJson(opts pipeline.PipelineOptions) pipeline.Step[string, JSON] {
 	return pipeline.NewStep(opts, func(line pipeline.Item[string], yield pipeline.Yield[JSON]) bool {
 		var res JSON
 		err := json.Unmarshal([]byte(line.Value), &res)
@@ -82,23 +83,22 @@ func StrToJson(opts pipeline.PipelineOptions, durationMs []string) pipeline.Step
 			res["raw"] = line
 		}
 
-		for _, durationField := range durationMs {
-			if dstr, ok := res[durationField]; ok {
-				d, err := time.ParseDuration(fmt.Sprintf("%v", dstr))
-				if err != nil {
-					continue
-				}
-				res[durationField] = d.Milliseconds()
-			}
-		}
-
 		return yield(pipeline.ToItem(line, res), nil)
 	})
 }
 
 func JsonToStr(opts pipeline.PipelineOptions) pipeline.Step[JSON, string] {
 	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[string]) bool {
-		b, err := json.Marshal(obj.Value)
+		value := obj.Value
+		if obj.Metadata.Removed && opts.KeepRemoved {
+			value = maps.Clone(value)
+			value["removed"] = obj.Metadata.RemovedBy
+		} else if obj.Metadata.Removed && opts.ContextEnabled {
+			value = maps.Clone(value)
+			value["context"] = true
+		}
+
+		b, err := json.Marshal(value)
 		return yield(pipeline.ToItem(obj, string(b)), err)
 	})
 }
@@ -134,22 +134,35 @@ func Hide(opts pipeline.PipelineOptions, properties []string) pipeline.Step[JSON
 	})
 }
 
-func FilterByKQL(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JSON, JSON], error) {
+// FilterByKQL removes records which do not match the KQL filter. Range clauses on the time properties
+// compare timestamps as times and accept date math like 'ts >= now-15m'.
+func FilterByKQL(opts pipeline.PipelineOptions, filter string, timeProps []string) (pipeline.Step[JSON, JSON], error) {
 	if len(filter) == 0 {
 		return Noop[JSON](), nil
 	}
 
-	expression, err := gokql.Parse(filter)
+	rewritten, timeFields, err := rewriteKQLTimeRanges(normalizeKQLKeywords(filter), timeProps, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	expression, err := gokql.Parse(rewritten)
 	if err != nil {
 		return nil, fmt.Errorf("filter parsing error: %w", err)
 	}
+	clauses := parseKQLClauses(rewritten)
+	if parts := splitKQLTopLevel(filter); len(parts) == len(clauses) {
+		// explain failures with the clauses as they were written
+		for i := range clauses {
+			clauses[i].text = parts[i]
+		}
+	}
 
 	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
 		if obj.Metadata.Removed {
 			return yield(obj, nil)
 		}
 
-		ev, err := gokql.NewMapEvaluator(map[string]any(obj.Value))
+		ev, err := gokql.NewMapEvaluator(map[string]any(withKQLTimes(obj.Value, timeFields)))
 		if err != nil {
 			return yield(obj.WithValue(nil), err)
 		}
@@ -158,36 +171,11 @@ func FilterByKQL(opts pipeline.PipelineOptions, filter string) (pipeline.Step[JS
 		if err != nil {
 			return yield(obj.WithValue(nil), err)
 		}
-		obj.Metadata.Removed = !matched
-		return yield(obj, nil)
-	}), nil
-}
-
-func DistinctBy(opts pipeline.PipelineOptions, property string) pipeline.Step[JSON, JSON] {
-	if len(property) == 0 {
-		return Noop[JSON]()
-	}
-
-	processed := make(map[string]struct{})
-
-	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
-		if obj.Metadata.Removed {
-			return yield(obj, nil)
+		if !matched {
+			obj.Metadata.Remove(opts, func() string { return kqlFailedClause(clauses, filter, ev) })
 		}
-
-		if v, ok := obj.Value[property]; ok {
-			key := fmt.Sprintf("%v", v)
-			if _, ok := processed[key]; ok {
-				obj.Metadata.Removed = true
-			} else {
-				processed[key] = struct{}{}
-			}
-			return yield(obj, nil)
-		}
-
-		obj.Metadata.Removed = true
 		return yield(obj, nil)
-	})
+	}), nil
 }
 
 func OpenFile(fileName string) (close func() error, reader io.Reader, err error) {
@@ -0,0 +1,36 @@
+package steps
+
+import (
+	"maps"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// cloneRecords returns shallow copies of the records for steps adding fields to them.
+func cloneRecords(in []JSON) []JSON {
+	res := make([]JSON, len(in))
+	for i, obj := range in {
+		res[i] = maps.Clone(obj)
+	}
+	return res
+}
+
+func sampled(in pipeline.Seq[JSON]) []JSON {
+	res := make([]JSON, 0)
+	for json, _ := range in {
+		if !json.Metadata.Removed {
+			res = append(res, json.Value)
+		}
+	}
+	return res
+}
+
+func itemsToSeq(items []pipeline.Item[JSON]) pipeline.Seq[JSON] {
+	return func(yield pipeline.Yield[JSON]) {
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package steps
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+// DefaultTimeFields are the property names commonly used for record timestamps.
+var DefaultTimeFields = []string{"ts", "@timestamp", "timestamp", "time"}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.ANSIC,
+}
+
+// ParseTime converts a timestamp value to time. Strings are parsed using common layouts,
+// timestamps without a time zone are treated as UTC. Numbers are treated as unix time
+// in seconds, milliseconds, microseconds or nanoseconds depending on their magnitude.
+func ParseTime(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case string:
+		s := strings.TrimSpace(val)
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && (n >= 1e17 || n <= -1e17) {
+			// nanoseconds exceed the precision of float64
+			return time.Unix(0, n).UTC(), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return unixTime(f), true
+		}
+	case float64:
+		return unixTime(val), true
+	case int:
+		return unixTime(float64(val)), true
+	case int64:
+		return unixTime(float64(val)), true
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			return unixTime(f), true
+		}
+	case time.Time:
+		return val, true
+	}
+	return time.Time{}, false
+}
+
+func unixTime(f float64) time.Time {
+	abs := math.Abs(f)
+	switch {
+	case abs >= 1e17:
+		return time.Unix(0, int64(f)).UTC()
+	case abs >= 1e14:
+		return time.UnixMicro(int64(f)).UTC()
+	case abs >= 1e11:
+		return time.UnixMilli(int64(f)).UTC()
+	default:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	}
+}
+
+// RecordTime returns the time of the first of the time properties found in the record.
+func RecordTime(obj JSON, timeProps []string) (time.Time, bool) {
+	for _, p := range timeProps {
+		if v, ok := obj[p]; ok {
+			return ParseTime(v)
+		}
+	}
+	return time.Time{}, false
+}
+
+// FindRecordTime reads the file up to the record with the specified number and returns its time.
+func FindRecordTime(fileName string, recNum int, timeProps []string) (time.Time, error) {
+	close, r, err := OpenFile(fileName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer close()
+
+	opts := pipeline.PipelineOptions{}
+	for line, err := range ReadByLines(fileName, r) {
+		if err != nil {
+			return time.Time{}, err
+		}
+		if line.Metadata.RecNum != recNum {
+			continue
+		}
+
+		for obj, err := range StrToJson(opts)(RemovePrefix(opts)(single(line))) {
+			if err != nil {
+				return time.Time{}, err
+			}
+			if t, ok := RecordTime(obj.Value, timeProps); ok {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("record %s:%d has no timestamp in properties %v", fileName, recNum, timeProps)
+	}
+
+	return time.Time{}, fmt.Errorf("record %s:%d not found", fileName, recNum)
+}
@@ -0,0 +1,30 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	expected := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	checkParseTime(t, "2024-03-01T10:00:00Z", expected)
+	checkParseTime(t, "2024-03-01T12:00:00+02:00", expected)
+	checkParseTime(t, "2024-03-01 10:00:00", expected)
+	checkParseTime(t, "2024-03-01T10:00", expected)
+	checkParseTime(t, float64(expected.Unix()), expected)
+	checkParseTime(t, float64(expected.UnixMilli()), expected)
+	checkParseTime(t, float64(expected.UnixNano()), expected)
+	checkParseTime(t, "1709287200", expected)
+
+	_, ok := ParseTime("yesterday")
+	assert.False(t, ok)
+}
+
+func checkParseTime(t *testing.T, v any, expected time.Time) {
+	t.Helper()
+	res, ok := ParseTime(v)
+	assert.True(t, ok)
+	assert.True(t, expected.Equal(res), "expected %v, got %v", expected, res)
+}
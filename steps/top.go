@@ -0,0 +1,147 @@
+package steps
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TopValue is a property value with the number of records containing it.
+type TopValue struct {
+	Value any
+	Count int
+	// Error is the maximum overestimation of the count in the approximate mode
+	Error int
+}
+
+type topEntry struct {
+	TopValue
+	key   string
+	index int
+}
+
+// TopCounter counts the records by the values of a property. Every element of arrays is counted.
+// With a capacity the counts are approximated by the Space-Saving algorithm keeping at most capacity values,
+// so the most frequent values are found in constant memory.
+type TopCounter struct {
+	Field    string
+	path     []string
+	capacity int
+	records  int
+	missing  int
+	entries  map[string]*topEntry
+	// minHeap orders the entries by count in the approximate mode
+	minHeap topHeap
+}
+
+func NewTopCounter(field string, capacity int) *TopCounter {
+	return &TopCounter{
+		Field:    field,
+		path:     SplitPath(field),
+		capacity: capacity,
+		entries:  make(map[string]*topEntry),
+	}
+}
+
+func (c *TopCounter) Add(obj JSON) {
+	c.records++
+	var seen []string
+	ValuesByPath(obj, c.path, func(v any) bool {
+		key := fmt.Sprintf("%T:%v", v, v)
+		// a value repeated in an array is counted once per record
+		if !slices.Contains(seen, key) {
+			seen = append(seen, key)
+			c.add(key, v)
+		}
+		return true
+	})
+	if len(seen) == 0 {
+		c.missing++
+	}
+}
+
+func (c *TopCounter) add(key string, v any) {
+	if e, ok := c.entries[key]; ok {
+		e.Count++
+		if c.capacity > 0 {
+			heap.Fix(&c.minHeap, e.index)
+		}
+		return
+	}
+
+	if c.capacity <= 0 || len(c.entries) < c.capacity {
+		e := &topEntry{TopValue: TopValue{Value: v, Count: 1}, key: key}
+		c.entries[key] = e
+		if c.capacity > 0 {
+			heap.Push(&c.minHeap, e)
+		}
+		return
+	}
+
+	// the least frequent value is replaced, the new value inherits its count as the possible error
+	e := c.minHeap[0]
+	delete(c.entries, e.key)
+	e.key = key
+	e.Value = v
+	e.Error = e.Count
+	e.Count++
+	c.entries[key] = e
+	heap.Fix(&c.minHeap, 0)
+}
+
+// Top returns at most n most frequent values, all values if n is not positive.
+func (c *TopCounter) Top(n int) []TopValue {
+	res := make([]TopValue, 0, len(c.entries))
+	for _, e := range c.entries {
+		res = append(res, e.TopValue)
+	}
+	slices.SortFunc(res, func(a, b TopValue) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(fmt.Sprint(a.Value), fmt.Sprint(b.Value))
+	})
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// Records returns the number of counted records.
+func (c *TopCounter) Records() int {
+	return c.records
+}
+
+// Missing returns the number of records without the property.
+func (c *TopCounter) Missing() int {
+	return c.missing
+}
+
+// Approximate reports whether the counts may be overestimated because some values were evicted.
+func (c *TopCounter) Approximate() bool {
+	return slices.ContainsFunc(c.minHeap, func(e *topEntry) bool { return e.Error > 0 })
+}
+
+type topHeap []*topEntry
+
+func (h topHeap) Len() int           { return len(h) }
+func (h topHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topHeap) Push(x any) {
+	e := x.(*topEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *topHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,53 @@
+package steps
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopCounter(t *testing.T) {
+	c := NewTopCounter("req.tags", 0)
+	for _, obj := range []JSON{
+		{"req": JSON{"tags": []any{"a", "b", "a"}}},
+		{"req": JSON{"tags": []any{"a"}}},
+		{"req": JSON{"tags": "c"}},
+		{"req": JSON{"tags": []any{float64(1)}}},
+		{"user": "alice"},
+	} {
+		c.Add(obj)
+	}
+
+	assert.Equal(t, []TopValue{{Value: "a", Count: 2}, {Value: float64(1), Count: 1}, {Value: "b", Count: 1}, {Value: "c", Count: 1}}, c.Top(0))
+	assert.Equal(t, []TopValue{{Value: "a", Count: 2}}, c.Top(1))
+	assert.Equal(t, 5, c.Records())
+	assert.Equal(t, 1, c.Missing())
+	assert.False(t, c.Approximate())
+}
+
+func TestTopCounterApproximate(t *testing.T) {
+	c := NewTopCounter("user", 10)
+	// heavy hitters interleaved with a long tail of unique values
+	for i := range 10000 {
+		user := fmt.Sprintf("user%d", i)
+		switch {
+		case i%3 == 0:
+			user = "alice"
+		case i%5 == 0:
+			user = "bob"
+		}
+		c.Add(JSON{"user": user})
+	}
+
+	top := c.Top(2)
+	assert.Equal(t, "alice", top[0].Value)
+	assert.Equal(t, "bob", top[1].Value)
+	// counts are never underestimated and the error bounds the overestimation
+	assert.GreaterOrEqual(t, top[0].Count, 3334)
+	assert.LessOrEqual(t, top[0].Count-top[0].Error, 3334)
+	assert.GreaterOrEqual(t, top[1].Count, 1333)
+	assert.LessOrEqual(t, top[1].Count-top[1].Error, 1333)
+	assert.True(t, c.Approximate())
+	assert.Len(t, c.Top(0), 10)
+}
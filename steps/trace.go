@@ -0,0 +1,278 @@
+package steps
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TraceFields are the names of the span properties.
+type TraceFields struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	// Name are the properties with the span name, the first found is used
+	Name []string
+	// Start are the properties with the span start time, the first found is used
+	Start []string
+	// End is the property with the span end time, used if there is no duration
+	End string
+	// Duration is the property with the span duration: a duration string like "15ms" or a number of DurationUnit
+	Duration     string
+	DurationUnit time.Duration
+}
+
+// TraceConventions are the span property names of common tracing formats.
+var TraceConventions = map[string]TraceFields{
+	"otel": {
+		TraceID:  "traceId",
+		SpanID:   "spanId",
+		ParentID: "parentSpanId",
+		Name:     []string{"name"},
+		Start:    []string{"startTimeUnixNano"},
+		End:      "endTimeUnixNano",
+	},
+	"zipkin": {
+		TraceID:      "traceId",
+		SpanID:       "id",
+		ParentID:     "parentId",
+		Name:         []string{"name"},
+		Start:        []string{"timestamp"},
+		Duration:     "duration",
+		DurationUnit: time.Microsecond,
+	},
+}
+
+// Span is a span of a trace built from one or several records with the same span id.
+// Records of the trace without a span id are spans without children.
+type Span struct {
+	ID       string
+	ParentID string
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	// HasDuration is false if no record of the span has a duration or an end time
+	HasDuration bool
+	// Orphan is true if the parent span is not found in the trace
+	Orphan   bool
+	Record   JSON
+	Children []*Span
+}
+
+// End returns the end time of the span.
+func (s *Span) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// Trace is a tree of spans with the same trace id.
+type Trace struct {
+	ID    string
+	Start time.Time
+	End   time.Time
+	Spans int
+	Roots []*Span
+}
+
+// Walk yields the spans in depth-first order with their depth, children are ordered by start time.
+func (t *Trace) Walk(yield func(span *Span, depth int) bool) {
+	var walk func(spans []*Span, depth int) bool
+	walk = func(spans []*Span, depth int) bool {
+		for _, s := range spans {
+			if !yield(s, depth) || !walk(s.Children, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(t.Roots, 0)
+}
+
+// TraceBuilder groups records by the trace id and builds span hierarchies.
+type TraceBuilder struct {
+	fields  TraceFields
+	traces  map[string][]*Span
+	spans   map[[2]string]*Span
+	skipped int
+}
+
+func NewTraceBuilder(fields TraceFields) *TraceBuilder {
+	if fields.DurationUnit == 0 {
+		fields.DurationUnit = time.Millisecond
+	}
+	return &TraceBuilder{
+		fields: fields,
+		traces: make(map[string][]*Span),
+		spans:  make(map[[2]string]*Span),
+	}
+}
+
+// Add adds the record to its trace, records without a trace id are ignored
+// and records without a start time are skipped.
+func (b *TraceBuilder) Add(obj JSON) {
+	traceID := traceString(obj[b.fields.TraceID])
+	if len(traceID) == 0 {
+		return
+	}
+	start, ok := RecordTime(obj, b.fields.Start)
+	if !ok {
+		b.skipped++
+		return
+	}
+
+	span := &Span{
+		ID:       traceString(obj[b.fields.SpanID]),
+		ParentID: traceString(obj[b.fields.ParentID]),
+		Start:    start,
+		Record:   obj,
+	}
+	for _, p := range b.fields.Name {
+		if v, ok := obj[p]; ok {
+			span.Name = fmt.Sprint(v)
+			break
+		}
+	}
+	span.Duration, span.HasDuration = b.duration(obj, start)
+
+	if len(span.ID) > 0 {
+		key := [2]string{traceID, span.ID}
+		if s, ok := b.spans[key]; ok {
+			s.merge(span)
+			return
+		}
+		b.spans[key] = span
+	}
+	b.traces[traceID] = append(b.traces[traceID], span)
+}
+
+func (b *TraceBuilder) duration(obj JSON, start time.Time) (time.Duration, bool) {
+	if len(b.fields.Duration) > 0 {
+		switch v := obj[b.fields.Duration].(type) {
+		case float64:
+			return time.Duration(v * float64(b.fields.DurationUnit)), true
+		case string:
+			if d, err := time.ParseDuration(v); err == nil {
+				return d, true
+			}
+		}
+	}
+	if len(b.fields.End) > 0 {
+		if end, ok := RecordTime(obj, []string{b.fields.End}); ok && !end.Before(start) {
+			return end.Sub(start), true
+		}
+	}
+	return 0, false
+}
+
+// merge combines records of the same span, for example the records logged at the start and at the end of an operation.
+func (s *Span) merge(other *Span) {
+	end := maxTime(s.End(), other.End())
+	s.HasDuration = s.HasDuration || other.HasDuration || !other.Start.Equal(s.Start)
+	if other.Start.Before(s.Start) {
+		s.Start = other.Start
+		s.Record = other.Record
+	}
+	s.Duration = end.Sub(s.Start)
+	if len(s.ParentID) == 0 {
+		s.ParentID = other.ParentID
+	}
+	if len(s.Name) == 0 {
+		s.Name = other.Name
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Skipped returns the number of records with a trace id but without a start time.
+func (b *TraceBuilder) Skipped() int {
+	return b.skipped
+}
+
+// Traces returns the traces sorted by start time.
+func (b *TraceBuilder) Traces() []*Trace {
+	res := make([]*Trace, 0, len(b.traces))
+	for id, spans := range b.traces {
+		res = append(res, buildTrace(id, spans))
+	}
+	slices.SortFunc(res, func(a, b *Trace) int {
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return res
+}
+
+func buildTrace(id string, spans []*Span) *Trace {
+	slices.SortStableFunc(spans, func(a, b *Span) int { return a.Start.Compare(b.Start) })
+
+	t := &Trace{ID: id, Spans: len(spans), Start: spans[0].Start}
+	byID := make(map[string]*Span, len(spans))
+	for _, s := range spans {
+		if len(s.ID) > 0 {
+			byID[s.ID] = s
+		}
+		t.End = maxTime(t.End, s.End())
+	}
+
+	var roots []*Span
+	for _, s := range spans {
+		parent, ok := byID[s.ParentID]
+		switch {
+		case len(s.ParentID) == 0 || s.ParentID == s.ID:
+			roots = append(roots, s)
+		case !ok:
+			s.Orphan = true
+			roots = append(roots, s)
+		default:
+			parent.Children = append(parent.Children, s)
+		}
+	}
+
+	// spans in parent cycles are not reachable from the roots, the earliest span of a cycle becomes a root
+	reachable := make(map[*Span]bool, len(spans))
+	var mark func(s *Span)
+	mark = func(s *Span) {
+		reachable[s] = true
+		for _, c := range s.Children {
+			if !reachable[c] {
+				mark(c)
+			}
+		}
+	}
+	for _, s := range roots {
+		mark(s)
+	}
+	for _, s := range spans {
+		if reachable[s] {
+			continue
+		}
+		parent := byID[s.ParentID]
+		parent.Children = slices.DeleteFunc(parent.Children, func(c *Span) bool { return c == s })
+		s.Orphan = true
+		roots = append(roots, s)
+		mark(s)
+	}
+
+	slices.SortStableFunc(roots, func(a, b *Span) int { return a.Start.Compare(b.Start) })
+	t.Roots = roots
+	return t
+}
+
+func traceString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return fmt.Sprint(int64(val))
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,102 @@
+package steps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type walkedSpan struct {
+	id    string
+	depth int
+}
+
+func walkTrace(t *Trace) []walkedSpan {
+	var res []walkedSpan
+	t.Walk(func(s *Span, depth int) bool {
+		res = append(res, walkedSpan{s.ID, depth})
+		return true
+	})
+	return res
+}
+
+func TestTraceBuilder(t *testing.T) {
+	b := NewTraceBuilder(TraceFields{
+		TraceID:  "trace_id",
+		SpanID:   "span_id",
+		ParentID: "parent_span_id",
+		Name:     []string{"name"},
+		Start:    []string{"ts"},
+		Duration: "duration",
+	})
+	for _, obj := range []JSON{
+		{"ts": "2024-03-01T10:00:00.060Z", "trace_id": "a", "span_id": "3", "parent_span_id": "1", "name": "list", "duration": "100ms"},
+		{"ts": "2024-03-01T10:00:00Z", "trace_id": "a", "span_id": "1", "name": "GET", "duration": float64(250)},
+		{"ts": "2024-03-01T10:00:00.010Z", "trace_id": "a", "span_id": "2", "parent_span_id": "1", "name": "auth"},
+		// the end of the auth span is logged separately
+		{"ts": "2024-03-01T10:00:00.050Z", "trace_id": "a", "span_id": "2"},
+		{"ts": "2024-03-01T10:00:00.070Z", "trace_id": "a", "span_id": "4", "parent_span_id": "3"},
+		{"ts": "2024-03-01T10:00:00.080Z", "trace_id": "a", "span_id": "5", "parent_span_id": "9"},
+		{"ts": "2024-03-01T09:00:00Z", "trace_id": "b", "span_id": "1", "duration": "1s"},
+		{"trace_id": "c", "span_id": "1"},
+		{"ts": "2024-03-01T10:00:00Z", "span_id": "1"},
+	} {
+		b.Add(obj)
+	}
+
+	assert.Equal(t, 1, b.Skipped())
+	traces := b.Traces()
+	require.Len(t, traces, 2)
+	assert.Equal(t, "b", traces[0].ID)
+
+	tr := traces[1]
+	assert.Equal(t, "a", tr.ID)
+	assert.Equal(t, 5, tr.Spans)
+	assert.Equal(t, 250*time.Millisecond, tr.End.Sub(tr.Start))
+	assert.Equal(t, []walkedSpan{{"1", 0}, {"2", 1}, {"3", 1}, {"4", 2}, {"5", 0}}, walkTrace(tr))
+
+	root := tr.Roots[0]
+	assert.Equal(t, "GET", root.Name)
+	assert.Equal(t, 250*time.Millisecond, root.Duration)
+
+	auth := root.Children[0]
+	assert.Equal(t, "auth", auth.Name)
+	assert.True(t, auth.HasDuration)
+	assert.Equal(t, 40*time.Millisecond, auth.Duration)
+
+	assert.False(t, root.Children[1].Children[0].HasDuration)
+	assert.True(t, tr.Roots[1].Orphan)
+}
+
+func TestTraceBuilderCycle(t *testing.T) {
+	b := NewTraceBuilder(TraceFields{TraceID: "t", SpanID: "s", ParentID: "p", Start: []string{"ts"}})
+	b.Add(JSON{"ts": float64(1000), "t": "a", "s": "1", "p": "2"})
+	b.Add(JSON{"ts": float64(1001), "t": "a", "s": "2", "p": "1"})
+
+	traces := b.Traces()
+	require.Len(t, traces, 1)
+	assert.Equal(t, []walkedSpan{{"1", 0}, {"2", 1}}, walkTrace(traces[0]))
+	assert.True(t, traces[0].Roots[0].Orphan)
+}
+
+func TestTraceConventions(t *testing.T) {
+	b := NewTraceBuilder(TraceConventions["zipkin"])
+	b.Add(JSON{"traceId": "a", "id": "1", "name": "get", "timestamp": float64(1709287200000000), "duration": float64(1500)})
+	b.Add(JSON{"traceId": "a", "id": "2", "parentId": "1", "name": "query", "timestamp": float64(1709287200000500), "duration": float64(700)})
+
+	traces := b.Traces()
+	require.Len(t, traces, 1)
+	root := traces[0].Roots[0]
+	assert.Equal(t, 1500*time.Microsecond, root.Duration)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), root.Start)
+	require.Len(t, root.Children, 1)
+	assert.Equal(t, 700*time.Microsecond, root.Children[0].Duration)
+
+	b = NewTraceBuilder(TraceConventions["otel"])
+	b.Add(JSON{"traceId": "a", "spanId": "1", "name": "get", "startTimeUnixNano": "1709287200000000000", "endTimeUnixNano": "1709287200002000000"})
+	traces = b.Traces()
+	require.Len(t, traces, 1)
+	assert.Equal(t, 2*time.Millisecond, traces[0].Roots[0].Duration)
+}
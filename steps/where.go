@@ -0,0 +1,159 @@
+package steps
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charlievieth/strcase"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+type fieldCondition struct {
+	text  string
+	path  []string
+	match func(value string) bool
+}
+
+var fieldConditionParams = []pipeline.ParamDef{
+	{Name: "case", Type: pipeline.ParamBool, Help: "case sensitive comparison"},
+	{Name: "word", Type: pipeline.ParamBool, Help: "match whole words only"},
+}
+
+// parseFieldCondition parses a condition in the format 'path OP value [case=true] [word=true]'
+// where OP is '=' for equality, ':' for a substring and '~' for a regular expression.
+func parseFieldCondition(spec string) (*fieldCondition, error) {
+	fields, err := pipeline.SplitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+
+	opIndex := strings.IndexAny(fields[0], "=:~")
+	if opIndex <= 0 {
+		return nil, fmt.Errorf("invalid condition %q, expected 'path=value', 'path:substring' or 'path~regexp'", spec)
+	}
+	path, op, value := fields[0][:opIndex], fields[0][opIndex], fields[0][opIndex+1:]
+
+	params, err := pipeline.ParseParams(fieldConditionParams, fields[1:])
+	if err != nil {
+		return nil, fmt.Errorf("condition %q: %w", spec, err)
+	}
+	caseSensitive, word := params.Bool("case"), params.Bool("word")
+	if op == '=' && word {
+		return nil, fmt.Errorf("condition %q: word=true is not supported for '=', the whole value is compared", spec)
+	}
+
+	var match func(string) bool
+	switch {
+	case op == '=' && caseSensitive:
+		match = func(s string) bool { return s == value }
+	case op == '=':
+		match = func(s string) bool { return strings.EqualFold(s, value) }
+	case op == ':' && !word && caseSensitive:
+		match = func(s string) bool { return strings.Contains(s, value) }
+	case op == ':' && !word:
+		match = func(s string) bool { return strcase.Contains(s, value) }
+	default:
+		expr := value
+		if op == ':' {
+			expr = regexp.QuoteMeta(value)
+		}
+		if word {
+			expr = `\b(?:` + expr + `)\b`
+		}
+		if !caseSensitive {
+			expr = "(?i)" + expr
+		}
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %w", value, err)
+		}
+		match = r.MatchString
+	}
+
+	return &fieldCondition{
+		text:  fields[0],
+		path:  SplitPath(path),
+		match: match,
+	}, nil
+}
+
+func (c *fieldCondition) matches(obj JSON) bool {
+	matched := false
+	ValuesByPath(obj, c.path, func(v any) bool {
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		matched = c.match(s)
+		return !matched
+	})
+	return matched
+}
+
+func parseFieldConditions(specs []string) ([]*fieldCondition, error) {
+	res := make([]*fieldCondition, len(specs))
+	for i, spec := range specs {
+		c, err := parseFieldCondition(spec)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = c
+	}
+	return res, nil
+}
+
+// Where keeps records matching all the conditions.
+func Where(opts pipeline.PipelineOptions, conditions []string) (pipeline.Step[JSON, JSON], error) {
+	if len(conditions) == 0 {
+		return Noop[JSON](), nil
+	}
+
+	cs, err := parseFieldConditions(conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		for _, c := range cs {
+			if !c.matches(obj.Value) {
+				obj.Metadata.Remove(opts, func() string { return fmt.Sprintf("does not match %q", c.text) })
+				break
+			}
+		}
+		return yield(obj, nil)
+	}), nil
+}
+
+// WhereNot removes records matching any of the conditions.
+func WhereNot(opts pipeline.PipelineOptions, conditions []string) (pipeline.Step[JSON, JSON], error) {
+	if len(conditions) == 0 {
+		return Noop[JSON](), nil
+	}
+
+	cs, err := parseFieldConditions(conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	return pipeline.NewStep(opts, func(obj pipeline.Item[JSON], yield pipeline.Yield[JSON]) bool {
+		if obj.Metadata.Removed {
+			return yield(obj, nil)
+		}
+
+		for _, c := range cs {
+			if c.matches(obj.Value) {
+				obj.Metadata.Remove(opts, func() string { return fmt.Sprintf("matches %q", c.text) })
+				break
+			}
+		}
+		return yield(obj, nil)
+	}), nil
+}
@@ -0,0 +1,58 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vladimir-rom/logex/pipeline"
+)
+
+var whereRecords = []JSON{
+	{"msg": "Timeout in db", "req": JSON{"user": "alice"}, "code": float64(500)},
+	{"msg": "timeouts in cache", "req": JSON{"user": "Bob"}, "code": float64(200)},
+	{"msg": "ok", "req": JSON{"user": "carol"}},
+}
+
+func TestWhere(t *testing.T) {
+	in := whereRecords
+	for _, tc := range []struct {
+		condition string
+		expected  []JSON
+	}{
+		{"req.user=bob", []JSON{in[1]}},
+		{"req.user=bob case=true", []JSON{}},
+		{"req.user=Bob case=true", []JSON{in[1]}},
+		{"code=500", []JSON{in[0]}},
+		{"msg:timeout", []JSON{in[0], in[1]}},
+		{"msg:timeout case=true", []JSON{in[1]}},
+		{"msg:timeout word=true", []JSON{in[0]}},
+		{"msg~time.*(db|cache)", []JSON{in[0], in[1]}},
+		{"msg~^t case=true", []JSON{in[1]}},
+		{"msg~'in db' word=true", []JSON{in[0]}},
+		{"msg~'in d' word=true", []JSON{}},
+		{"missing:x", []JSON{}},
+	} {
+		step, err := Where(pipeline.PipelineOptions{}, []string{tc.condition})
+		require.NoError(t, err, tc.condition)
+		assert.Equal(t, tc.expected, sampled(step(sliceToSeq(in))), tc.condition)
+	}
+}
+
+func TestWhereNot(t *testing.T) {
+	in := whereRecords
+	step, err := WhereNot(pipeline.PipelineOptions{}, []string{"msg:timeout", "req.user=carol"})
+	require.NoError(t, err)
+	assert.Empty(t, sampled(step(sliceToSeq(in))))
+
+	step, err = WhereNot(pipeline.PipelineOptions{}, []string{"code=500"})
+	require.NoError(t, err)
+	assert.Equal(t, []JSON{in[1], in[2]}, sampled(step(sliceToSeq(in))))
+}
+
+func TestWhereInvalid(t *testing.T) {
+	for _, condition := range []string{"", "=x", "msg", "msg~(", "msg=x word=true", "msg:x foo=true"} {
+		_, err := Where(pipeline.PipelineOptions{}, []string{condition})
+		assert.Error(t, err, condition)
+	}
+}